@@ -25,6 +25,14 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "tags" {
+		err := cli.RunTags(config.ParseTagsFlags(os.Args[2:]))
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg := config.ParseFlags()
 	cli := cli.NewCLI(cfg)
 
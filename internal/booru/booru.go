@@ -19,23 +19,58 @@
 package booru
 
 import (
-	"errors"
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 type Metadata struct {
+	ID         int64    `json:"id"`
+	PostURL    string   `json:"post_url"`
 	Tags       []string `json:"tags"`
 	Copyright  []string `json:"copyright"`
 	Characters []string `json:"characters"`
 	Artists    []string `json:"artists"`
-	Hash       string   `json:"hash"`
-	FromHost   string   `json:"from_host"`
-	URL        string   `json:"url"`
-	Size       uint64   `json:"size"`
+	Species    []string `json:"species,omitempty"`
+	Lore       []string `json:"lore,omitempty"`
+	Meta       []string `json:"meta,omitempty"`
+	Rating     Rating   `json:"rating"`
+	Score      int      `json:"score"`
+	// Not reported by gelbooru and moebooru
+	FavCount *int `json:"fav_count"`
+	Width    int  `json:"width"`
+	Height   int  `json:"height"`
+	// Length of videos in seconds, where known
+	Duration float64 `json:"duration,omitempty"`
+	MD5      string  `json:"md5"`
+	Source   string  `json:"source"`
+	// RFC 3339 time of upload
+	CreatedAt  string      `json:"created_at"`
+	Hash       string      `json:"hash"`
+	FromHost   string      `json:"from_host"`
+	URL        string      `json:"url"`
+	Size       uint64      `json:"size"`
+	Relations  *Relations  `json:"relations,omitempty"`
+	PixivID    int64       `json:"pixiv_id,omitempty"`
+	Commentary *Commentary `json:"commentary,omitempty"`
+	// Type of the saved media variant, blank for the original
+	Variant string `json:"variant,omitempty"`
 }
 
 type Post interface {
+	PostID() int64
+	// Rating of the post, RatingUnknown if the booru does not tell
+	PostRating() Rating
+	// Time of upload, zero if the booru does not tell
+	CreatedTime() time.Time
 	MediaURL() string
 	Tags() []string
 	Artists() []string
@@ -49,37 +84,141 @@ type Post interface {
 	Size() uint64
 }
 
-var ErrBooruNotSupported error = errors.New("this booru is not supported")
+// Downloads media from mediaURL and saves it in directory under its SHA256 hash.
+// Returns the hash and the size of saved media in bytes
+func saveMedia(mediaURL string, directory string, client *http.Client) (string, uint64, error) {
+	// Get file contents
+	contents, err := proxy.GetContents(client, mediaURL)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Calculate hash
+	hasher := sha256.New()
+	hasher.Write(contents)
+	mediaHash := hex.EncodeToString(hasher.Sum(nil))
+
+	// Save media
+	path := filepath.Join(directory, mediaFileName(mediaHash, mediaURL))
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
 
-func GetPosts(booruURL url.URL, page uint, tags string, client *http.Client) ([]Post, error) {
-	switch booruURL.Hostname() {
-	case "danbooru.donmai.us":
-		danbooruPosts, err := GetPostsDanbooru(booruURL, page, tags, client)
-		if err != nil {
-			return nil, err
-		}
+	_, err = file.Write(contents)
+	if err != nil {
+		return "", 0, err
+	}
 
-		posts := make([]Post, len(danbooruPosts))
-		for i, post := range danbooruPosts {
-			posts[i] = &post
-		}
+	return mediaHash, uint64(len(contents)), nil
+}
 
-		return posts, nil
+// Returns name of the file media from mediaURL is saved under
+func mediaFileName(mediaHash string, mediaURL string) string {
+	return mediaHash + filepath.Ext(mediaURL)
+}
 
-	case "gelbooru.com":
-		gelbooruPosts, err := GetPostsGelbooru(booruURL, page, tags, client)
-		if err != nil {
-			return nil, err
-		}
+// Returns name of the saved media file metadata belongs to
+func (metadata *Metadata) FileName() string {
+	return mediaFileName(metadata.Hash, metadata.URL)
+}
 
-		posts := make([]Post, len(gelbooruPosts))
-		for i, post := range gelbooruPosts {
-			posts[i] = &post
-		}
+// Writes metadata as a <hash>_metadata.json file in directory
+func saveMetadata(metadata *Metadata, directory string) error {
+	file, err := os.Create(
+		filepath.Join(
+			directory,
+			fmt.Sprintf("%s_metadata.json", metadata.Hash),
+		),
+	)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-		return posts, nil
+	contents, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(contents)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func isImageExtension(extension string) bool {
+	imageExtensions := map[string]bool{
+		"jpg":  true,
+		"jpeg": true,
+		"png":  true,
+		"gif":  true,
+		"webp": true,
+		"bmp":  true,
+		"tiff": true,
+	}
+
+	return imageExtensions[strings.TrimPrefix(strings.ToLower(extension), ".")]
+}
 
-	default:
-		return nil, ErrBooruNotSupported
+func isVideoExtension(extension string) bool {
+	videoExtensions := map[string]bool{
+		"mp4":  true,
+		"webm": true,
+		"avi":  true,
+		"mov":  true,
+		"mkv":  true,
+		"flv":  true,
+		"wmv":  true,
 	}
+
+	return videoExtensions[strings.TrimPrefix(strings.ToLower(extension), ".")]
+}
+
+// Resolves a possibly relative or protocol-relative media URL against the booru URL
+func resolveURL(booruURL url.URL, reference string) string {
+	if reference == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(reference)
+	if err != nil {
+		return reference
+	}
+
+	return booruURL.ResolveReference(parsed).String()
+}
+
+// Returns URL of the booru page at path, such as a post page
+func pageURL(booruURL url.URL, path string, query string) string {
+	booruURL.User = nil
+	booruURL.Path = path
+	booruURL.RawQuery = query
+	booruURL.Fragment = ""
+
+	return booruURL.String()
+}
+
+// Formats time of metadata as RFC 3339, unknown (zero) time as a blank string
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// Returns a pointer to count, so that unreported counts can be told apart from zero
+func reportedCount(count int) *int {
+	return &count
+}
+
+// Returns the first of newline separated sources
+func firstSource(sources string) string {
+	source, _, _ := strings.Cut(strings.TrimSpace(sources), "\n")
+	return strings.TrimSpace(source)
 }
@@ -0,0 +1,98 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/time/rate"
+)
+
+type CollectionKind string
+
+const (
+	CollectionPool          CollectionKind = "pool"
+	CollectionFavoriteGroup CollectionKind = "favorite_group"
+)
+
+// An ordered set of posts, such as a pool of comic pages
+type Collection struct {
+	Kind        CollectionKind
+	ID          int64
+	Name        string
+	Description string
+	Category    string
+	FromHost    string
+	// Post IDs in reading order
+	PostIDs []int64
+}
+
+// Implemented by providers of boorus with ordered post collections
+type CollectionProvider interface {
+	GetCollection(site *Site, kind CollectionKind, id int64) (*Collection, error)
+}
+
+// Implemented by providers able to look up many posts by ID at once
+type PostBatchProvider interface {
+	// Retrieves posts with given IDs, in no particular order, waiting for limiter
+	// before each request. Missing posts are left out
+	GetPostsByID(site *Site, ids []int64, limiter *rate.Limiter) ([]Post, error)
+}
+
+var ErrNotSupported error = errors.New("not supported by this booru engine")
+
+// Retrieves a collection of posts
+func (site *Site) GetCollection(kind CollectionKind, id int64) (*Collection, error) {
+	provider, ok := site.Provider.(CollectionProvider)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", kind, ErrNotSupported)
+	}
+
+	return provider.GetCollection(site, kind, id)
+}
+
+// Retrieves posts with given IDs, in no particular order, waiting for limiter
+// before each request. Missing posts are left out
+func (site *Site) GetPostsByID(ids []int64, limiter *rate.Limiter) ([]Post, error) {
+	provider, ok := site.Provider.(PostBatchProvider)
+	if ok {
+		return provider.GetPostsByID(site, ids, limiter)
+	}
+
+	// Fall back to one request per post
+	var posts []Post
+	for _, id := range ids {
+		if err := limiter.Wait(context.Background()); err != nil {
+			return posts, err
+		}
+
+		post, err := site.GetPost(id)
+		if errors.Is(err, ErrPostNotFound) {
+			continue
+		}
+		if err != nil {
+			return posts, err
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, nil
+}
@@ -0,0 +1,47 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import "golang.org/x/time/rate"
+
+// Title and description the artist gave to the work, with translations
+type Commentary struct {
+	Title                 string `json:"title,omitempty"`
+	Description           string `json:"description,omitempty"`
+	TranslatedTitle       string `json:"translated_title,omitempty"`
+	TranslatedDescription string `json:"translated_description,omitempty"`
+}
+
+// Implemented by providers of boorus with artist commentary
+type CommentaryProvider interface {
+	// Retrieves artist commentary of post waiting for limiter before requests
+	// and keeps it in post, so it ends up in its metadata
+	FetchCommentary(site *Site, post Post, limiter *rate.Limiter) error
+}
+
+// Retrieves artist commentary of post, if the booru has any, and keeps it in post.
+// Limiter is waited for only if commentary is requested
+func (site *Site) FetchCommentary(post Post, limiter *rate.Limiter) error {
+	provider, ok := site.Provider.(CommentaryProvider)
+	if !ok {
+		return nil
+	}
+
+	return provider.FetchCommentary(site, post, limiter)
+}
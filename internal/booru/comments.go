@@ -0,0 +1,76 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/time/rate"
+)
+
+type Comment struct {
+	ID       int64  `json:"id"`
+	Author   string `json:"author"`
+	AuthorID int64  `json:"author_id,omitempty"`
+	// RFC3339 if the booru's date format is known, as given otherwise
+	CreatedAt string `json:"created_at"`
+	// Not every booru scores comments
+	Score *int   `json:"score,omitempty"`
+	Body  string `json:"body"`
+}
+
+// Comment thread of a single post, oldest comments first
+type PostComments struct {
+	PostID   int64     `json:"post_id"`
+	Comments []Comment `json:"comments"`
+}
+
+// Implemented by providers of boorus with post comments
+type CommentsProvider interface {
+	// Retrieves comments of post waiting for limiter before requests, nil if it has none
+	GetComments(site *Site, post Post, limiter *rate.Limiter) (*PostComments, error)
+}
+
+// Retrieves comments of post, nil if it has none or the booru has no comments.
+// Limiter is waited for only if comments are requested
+func (site *Site) GetComments(post Post, limiter *rate.Limiter) (*PostComments, error) {
+	provider, ok := site.Provider.(CommentsProvider)
+	if !ok {
+		return nil, nil
+	}
+
+	return provider.GetComments(site, post, limiter)
+}
+
+// Writes comments as a <hash>_comments.json file in directory
+func (comments *PostComments) Save(directory string, mediaHash string) error {
+	contents, err := json.Marshal(comments)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(
+		filepath.Join(directory, fmt.Sprintf("%s_comments.json", mediaHash)),
+		contents,
+		0644,
+	)
+}
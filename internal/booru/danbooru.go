@@ -20,20 +20,103 @@ package booru
 
 import (
 	"Unbewohnte/gobooru-downloader/internal/proxy"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 )
 
+func init() {
+	Register(danbooruProvider{})
+}
+
+type danbooruProvider struct{}
+
+func (danbooruProvider) Name() string {
+	return "danbooru"
+}
+
+func (danbooruProvider) Claims(booruURL url.URL) bool {
+	return isHostOf(booruURL.Hostname(), "donmai.us")
+}
+
+func (danbooruProvider) Probe(site *Site) bool {
+	probeURL := site.URL
+	probeURL.Path = "/posts.json"
+	probeURL.RawQuery = "limit=1"
+
+	var posts []DanbooruPost
+	return probeJSON(site.Client, probeURL, site.Credentials.basicAuthHeaders(), &posts)
+}
+
+func (danbooruProvider) GetPosts(site *Site, cursor Cursor, tags string) ([]Post, error) {
+	posts, err := GetPostsDanbooru(site.URL, cursor, tags, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return asPosts(posts), nil
+}
+
+func (danbooruProvider) OrderedByID(site *Site, tags string) bool {
+	return !hasCustomOrder(tags)
+}
+
+// Post pages look like /posts/<id>
+var danbooruPostPath = regexp.MustCompile(`^/posts/(\d+)`)
+
+func (danbooruProvider) ParsePostURL(postURL url.URL) (int64, bool) {
+	return parsePostPath(postURL, danbooruPostPath)
+}
+
+// Anonymous users and basic accounts may search for 2 tags at once, gold accounts for 6 and
+// platinum accounts and above for 12. 2 is assumed if the account level can not be looked up
+func (danbooruProvider) TagLimit(site *Site) int {
+	if site.Credentials.Login == "" || site.Credentials.APIKey == "" {
+		return 2
+	}
+
+	if err := site.wait(); err != nil {
+		return 2
+	}
+
+	profile, err := GetProfileDanbooru(site.URL, site.Credentials, site.Client)
+	if err != nil {
+		return 2
+	}
+
+	switch {
+	case profile.Level >= danbooruLevelPlatinum:
+		return 12
+	case profile.Level >= danbooruLevelGold:
+		return 6
+	default:
+		return 2
+	}
+}
+
+func (danbooruProvider) OrSyntax(site *Site) OrSyntax {
+	return OrTilde
+}
+
+func (danbooruProvider) WithDates(site *Site, tags string, since time.Time, until time.Time) string {
+	return strings.TrimSpace(tags + " " + dateMetatag(since, until))
+}
+
+func (danbooruProvider) FavoritesQuery(site *Site, user string) (string, bool) {
+	return "ordfav:" + user, true
+}
+
 type DanbooruPost struct {
+	chosenVariant
 	MediaHash           string
+	Host                string
+	PageURL             string
+	ChildIDs            []int64
+	Commentary          *Commentary
 	ID                  int64      `json:"id"`
 	CreatedAt           time.Time  `json:"created_at"`
 	UploaderID          int64      `json:"uploader_id"`
@@ -99,20 +182,15 @@ type MediaAsset struct {
 	Variants    []Variant `json:"variants"`
 }
 
-type Variant struct {
-	Type    string `json:"type"`
-	URL     string `json:"url"`
-	Width   int    `json:"width"`
-	Height  int    `json:"height"`
-	FileExt string `json:"file_ext"`
-}
-
-func GetPostsDanbooru(danbooruURL url.URL, page uint, tags string, client *http.Client) ([]DanbooruPost, error) {
+// Retrieves a page of posts. Login and API key, if given, are sent via HTTP basic authorization
+func GetPostsDanbooru(danbooruURL url.URL, cursor Cursor, tags string, credentials Credentials, client *http.Client) ([]DanbooruPost, error) {
 	query := danbooruURL.Query()
-	if page == 0 {
-		page = 1
+	if cursor.BeforeID != 0 {
+		// Danbooru refuses deep page numbers, but "b<id>" pages are unlimited
+		query.Set("page", fmt.Sprintf("b%d", cursor.BeforeID))
+	} else {
+		query.Set("page", fmt.Sprintf("%d", cursor.pageNumber()))
 	}
-	query.Set("page", fmt.Sprintf("%d", page))
 
 	if tags != "" {
 		query.Set("tags", tags)
@@ -120,7 +198,7 @@ func GetPostsDanbooru(danbooruURL url.URL, page uint, tags string, client *http.
 	danbooruURL.RawQuery = query.Encode()
 	danbooruURL.Path = "/posts.json"
 
-	data, err := proxy.GetContents(client, danbooruURL.String())
+	data, err := proxy.GetContentsWithHeaders(client, danbooruURL.String(), credentials.basicAuthHeaders())
 	if err != nil {
 		return nil, err
 	}
@@ -131,9 +209,67 @@ func GetPostsDanbooru(danbooruURL url.URL, page uint, tags string, client *http.
 		return nil, err
 	}
 
+	for i := range posts {
+		posts[i].Host = danbooruURL.Hostname()
+		posts[i].PageURL = pageURL(danbooruURL, fmt.Sprintf("/posts/%d", posts[i].ID), "")
+	}
+
 	return posts, nil
 }
 
+// Levels of danbooru accounts lifting the tag limit
+const (
+	danbooruLevelGold     int = 30
+	danbooruLevelPlatinum int = 31
+)
+
+// Account of the authenticated user
+type DanbooruProfile struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Level       int    `json:"level"`
+	LevelString string `json:"level_string"`
+}
+
+// Retrieves account of the user authenticated with login and API key
+func GetProfileDanbooru(danbooruURL url.URL, credentials Credentials, client *http.Client) (*DanbooruProfile, error) {
+	danbooruURL.Path = "/profile.json"
+	danbooruURL.RawQuery = ""
+
+	data, err := proxy.GetContentsWithHeaders(client, danbooruURL.String(), credentials.basicAuthHeaders())
+	if err != nil {
+		return nil, err
+	}
+
+	var profile DanbooruProfile
+	err = json.Unmarshal(data, &profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+func (post *DanbooruPost) PostID() int64 {
+	return post.ID
+}
+
+func (post *DanbooruPost) ParentPostID() int64 {
+	if post.ParentID == nil {
+		return 0
+	}
+
+	return *post.ParentID
+}
+
+func (post *DanbooruPost) HasChildPosts() bool {
+	return post.HasChildren
+}
+
+func (post *DanbooruPost) SetChildPostIDs(ids []int64) {
+	post.ChildIDs = ids
+}
+
 func (post *DanbooruPost) Tags() []string {
 	return strings.Fields(post.TagStringGeneral)
 }
@@ -159,6 +295,14 @@ func (post *DanbooruPost) FileExtension() string {
 }
 
 func (post *DanbooruPost) MediaURL() string {
+	if post.variant != nil {
+		return post.variant.URL
+	}
+
+	return post.originalURL()
+}
+
+func (post *DanbooruPost) originalURL() string {
 	if post.FileURL == "" {
 		// Fallback to large file URL
 		if post.LargeFileURL == "" {
@@ -173,103 +317,94 @@ func (post *DanbooruPost) MediaURL() string {
 }
 
 func (post *DanbooruPost) SaveMedia(directory string, client *http.Client) error {
-	// Get file contents
-	contents, err := proxy.GetContents(client, post.MediaURL())
+	mediaHash, size, err := saveMedia(post.MediaURL(), directory, client)
 	if err != nil {
 		return err
 	}
-
-	// Calculate hash
-	hasher := sha256.New()
-	hasher.Write(contents)
-	mediaHash := hex.EncodeToString(hasher.Sum(nil))
 	post.MediaHash = mediaHash
+	post.variantSize = size
 
-	fileExt := filepath.Ext(post.MediaURL())
-
-	// Save media
-	path := filepath.Join(directory, mediaHash+fileExt)
-
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+	return nil
+}
 
-	_, err = file.Write(contents)
-	if err != nil {
-		return err
+func (post *DanbooruPost) Variants() []Variant {
+	variants := post.MediaAsset.Variants
+	for _, variant := range variants {
+		if variant.Type == VariantOriginal {
+			return variants
+		}
 	}
 
-	return nil
+	return append(variants, Variant{
+		Type:    VariantOriginal,
+		URL:     post.originalURL(),
+		Width:   post.ImageWidth,
+		Height:  post.ImageHeight,
+		FileExt: post.FileExt,
+	})
 }
 
 func (post *DanbooruPost) SaveMetadata(directory string) error {
-	file, err := os.Create(
-		filepath.Join(
-			directory,
-			fmt.Sprintf("%s_metadata.json", post.MediaHash),
-		),
-	)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	contents, err := json.Marshal(post.Metadata())
-	if err != nil {
-		return err
-	}
+	return saveMetadata(post.Metadata(), directory)
+}
 
-	_, err = file.Write(contents)
-	if err != nil {
-		return err
-	}
+func (post *DanbooruPost) IsImage() bool {
+	return isImageExtension(post.FileExt)
+}
 
-	return nil
+func (post *DanbooruPost) IsVideo() bool {
+	return post.MediaAsset.Duration > 0.0 || isVideoExtension(post.FileExt)
 }
 
-func (post *DanbooruPost) IsImage() bool {
-	imageExtensions := map[string]bool{
-		"jpg":  true,
-		"jpeg": true,
-		"png":  true,
-		"gif":  true,
-		"webp": true,
-		"bmp":  true,
-		"tiff": true,
+func (post *DanbooruPost) Size() uint64 {
+	if post.variant != nil {
+		return post.variantSize
 	}
 
-	return imageExtensions[post.FileExt]
+	return uint64(post.FileSize)
 }
 
-func (post *DanbooruPost) IsVideo() bool {
-	videoExtensions := map[string]bool{
-		"mp4":  true,
-		"webm": true,
-		"avi":  true,
-		"mov":  true,
-		"mkv":  true,
-		"flv":  true,
-		"wmv":  true,
-	}
+func (post *DanbooruPost) PostRating() Rating {
+	return parseRating(post.Rating, danbooruRatingLetters)
+}
 
-	return post.MediaAsset.Duration > 0.0 || videoExtensions[post.FileExt]
+func (post *DanbooruPost) CreatedTime() time.Time {
+	return post.CreatedAt
 }
 
-func (post *DanbooruPost) Size() uint64 {
-	return uint64(post.FileSize)
+func (post *DanbooruPost) pixivID() int64 {
+	if post.PixivID == nil {
+		return 0
+	}
+
+	return *post.PixivID
 }
 
 func (post *DanbooruPost) Metadata() *Metadata {
 	return &Metadata{
+		ID:         post.ID,
+		PostURL:    post.PageURL,
 		Tags:       post.Tags(),
 		Copyright:  post.Copyright(),
 		Characters: post.Characters(),
 		Artists:    post.Artists(),
+		Meta:       post.Meta(),
+		Rating:     post.PostRating(),
+		Score:      post.Score,
+		FavCount:   reportedCount(post.FavCount),
+		Width:      post.ImageWidth,
+		Height:     post.ImageHeight,
+		Duration:   post.MediaAsset.Duration,
+		MD5:        post.MD5,
+		Source:     post.Source,
+		CreatedAt:  formatTime(post.CreatedTime()),
 		Hash:       post.MediaHash,
-		FromHost:   "danbooru.donmai.us",
+		FromHost:   post.Host,
 		URL:        post.MediaURL(),
 		Size:       post.Size(),
+		Relations:  relationsOf(post.ParentPostID(), post.HasChildPosts(), post.ChildIDs),
+		PixivID:    post.pixivID(),
+		Commentary: post.Commentary,
+		Variant:    post.variantType(),
 	}
 }
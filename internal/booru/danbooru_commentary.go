@@ -0,0 +1,94 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type DanbooruArtistCommentary struct {
+	ID                    int64     `json:"id"`
+	PostID                int64     `json:"post_id"`
+	OriginalTitle         string    `json:"original_title"`
+	OriginalDescription   string    `json:"original_description"`
+	TranslatedTitle       string    `json:"translated_title"`
+	TranslatedDescription string    `json:"translated_description"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+func (danbooruProvider) FetchCommentary(site *Site, post Post, limiter *rate.Limiter) error {
+	danbooruPost, ok := post.(*DanbooruPost)
+	if !ok {
+		return nil
+	}
+
+	if err := limiter.Wait(context.Background()); err != nil {
+		return err
+	}
+
+	commentary, err := GetArtistCommentaryDanbooru(site.URL, danbooruPost.ID, site.Credentials, site.Client)
+	if err != nil {
+		return err
+	}
+
+	if commentary != nil {
+		danbooruPost.Commentary = &Commentary{
+			Title:                 commentary.OriginalTitle,
+			Description:           commentary.OriginalDescription,
+			TranslatedTitle:       commentary.TranslatedTitle,
+			TranslatedDescription: commentary.TranslatedDescription,
+		}
+	}
+
+	return nil
+}
+
+// Retrieves artist commentary of the post with given ID, nil if there is none
+func GetArtistCommentaryDanbooru(danbooruURL url.URL, postID int64, credentials Credentials, client *http.Client) (*DanbooruArtistCommentary, error) {
+	query := url.Values{}
+	query.Set("search[post_id]", fmt.Sprintf("%d", postID))
+	danbooruURL.RawQuery = query.Encode()
+	danbooruURL.Path = "/artist_commentaries.json"
+
+	data, err := proxy.GetContentsWithHeaders(client, danbooruURL.String(), credentials.basicAuthHeaders())
+	if err != nil {
+		return nil, err
+	}
+
+	var commentaries []DanbooruArtistCommentary
+	err = json.Unmarshal(data, &commentaries)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(commentaries) == 0 {
+		return nil, nil
+	}
+
+	return &commentaries[0], nil
+}
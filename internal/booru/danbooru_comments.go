@@ -0,0 +1,119 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Most comments returned in a single request
+const danbooruCommentsLimit int = 1000
+
+type DanbooruComment struct {
+	ID        int64 `json:"id"`
+	PostID    int64 `json:"post_id"`
+	CreatorID int64 `json:"creator_id"`
+	Creator   struct {
+		Name string `json:"name"`
+	} `json:"creator"`
+	Body      string    `json:"body"`
+	Score     int       `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	IsDeleted bool      `json:"is_deleted"`
+	IsSticky  bool      `json:"is_sticky"`
+}
+
+func (danbooruProvider) GetComments(site *Site, post Post, limiter *rate.Limiter) (*PostComments, error) {
+	danbooruPost, ok := post.(*DanbooruPost)
+	if !ok || danbooruPost.LastCommentedAt == nil {
+		return nil, nil
+	}
+
+	if err := limiter.Wait(context.Background()); err != nil {
+		return nil, err
+	}
+
+	comments, err := GetCommentsDanbooru(site.URL, danbooruPost.ID, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Threads are read oldest first
+	slices.SortFunc(comments, func(a, b DanbooruComment) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	postComments := &PostComments{PostID: danbooruPost.ID}
+	for _, comment := range comments {
+		if comment.IsDeleted {
+			continue
+		}
+
+		score := comment.Score
+		postComments.Comments = append(postComments.Comments, Comment{
+			ID:        comment.ID,
+			Author:    comment.Creator.Name,
+			AuthorID:  comment.CreatorID,
+			CreatedAt: comment.CreatedAt.Format(time.RFC3339),
+			Score:     &score,
+			Body:      comment.Body,
+		})
+	}
+
+	if len(postComments.Comments) == 0 {
+		return nil, nil
+	}
+
+	return postComments, nil
+}
+
+// Retrieves comments of the post with given ID
+func GetCommentsDanbooru(danbooruURL url.URL, postID int64, credentials Credentials, client *http.Client) ([]DanbooruComment, error) {
+	query := url.Values{}
+	// Comments are grouped by post by default
+	query.Set("group_by", "comment")
+	query.Set("search[post_id]", fmt.Sprintf("%d", postID))
+	query.Set("limit", fmt.Sprintf("%d", danbooruCommentsLimit))
+	query.Set("only", "id,post_id,creator_id,creator[name],body,score,created_at,updated_at,is_deleted,is_sticky")
+	danbooruURL.RawQuery = query.Encode()
+	danbooruURL.Path = "/comments.json"
+
+	data, err := proxy.GetContentsWithHeaders(client, danbooruURL.String(), credentials.basicAuthHeaders())
+	if err != nil {
+		return nil, err
+	}
+
+	var comments []DanbooruComment
+	err = json.Unmarshal(data, &comments)
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
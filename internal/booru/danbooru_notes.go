@@ -0,0 +1,112 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Most notes returned in a single request
+const danbooruNotesLimit int = 1000
+
+type DanbooruNote struct {
+	ID        int64     `json:"id"`
+	PostID    int64     `json:"post_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	X         int       `json:"x"`
+	Y         int       `json:"y"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	IsActive  bool      `json:"is_active"`
+	Body      string    `json:"body"`
+	Version   int       `json:"version"`
+}
+
+func (danbooruProvider) GetNotes(site *Site, post Post, limiter *rate.Limiter) (*PostNotes, error) {
+	danbooruPost, ok := post.(*DanbooruPost)
+	if !ok || danbooruPost.LastNotedAt == nil {
+		return nil, nil
+	}
+
+	if err := limiter.Wait(context.Background()); err != nil {
+		return nil, err
+	}
+
+	notes, err := GetNotesDanbooru(site.URL, danbooruPost.ID, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	postNotes := &PostNotes{
+		PostID:      danbooruPost.ID,
+		ImageWidth:  danbooruPost.ImageWidth,
+		ImageHeight: danbooruPost.ImageHeight,
+	}
+	for _, note := range notes {
+		// Deleted notes are kept inactive
+		if !note.IsActive {
+			continue
+		}
+
+		postNotes.Notes = append(postNotes.Notes, Note{
+			X:      note.X,
+			Y:      note.Y,
+			Width:  note.Width,
+			Height: note.Height,
+			Body:   note.Body,
+		})
+	}
+
+	if len(postNotes.Notes) == 0 {
+		return nil, nil
+	}
+
+	return postNotes, nil
+}
+
+// Retrieves notes of the post with given ID
+func GetNotesDanbooru(danbooruURL url.URL, postID int64, credentials Credentials, client *http.Client) ([]DanbooruNote, error) {
+	query := url.Values{}
+	query.Set("search[post_id]", fmt.Sprintf("%d", postID))
+	query.Set("limit", fmt.Sprintf("%d", danbooruNotesLimit))
+	danbooruURL.RawQuery = query.Encode()
+	danbooruURL.Path = "/notes.json"
+
+	data, err := proxy.GetContentsWithHeaders(client, danbooruURL.String(), credentials.basicAuthHeaders())
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []DanbooruNote
+	err = json.Unmarshal(data, &notes)
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
@@ -0,0 +1,124 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Amount of posts looked up by ID in a single request
+const danbooruBatchSize int = 100
+
+// Pool or favorite group, the latter has no description nor category
+type DanbooruCollection struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Category    string    `json:"category"`
+	PostIDs     []int64   `json:"post_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	IsDeleted   bool      `json:"is_deleted"`
+}
+
+func (danbooruProvider) GetCollection(site *Site, kind CollectionKind, id int64) (*Collection, error) {
+	collection, err := GetCollectionDanbooru(site.URL, kind, id, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Collection{
+		Kind:        kind,
+		ID:          collection.ID,
+		Name:        strings.ReplaceAll(collection.Name, "_", " "),
+		Description: collection.Description,
+		Category:    collection.Category,
+		FromHost:    site.URL.Hostname(),
+		PostIDs:     collection.PostIDs,
+	}, nil
+}
+
+func (danbooruProvider) GetPostsByID(site *Site, ids []int64, limiter *rate.Limiter) ([]Post, error) {
+	var posts []Post
+	for start := 0; start < len(ids); start += danbooruBatchSize {
+		batch := ids[start:min(start+danbooruBatchSize, len(ids))]
+
+		if err := limiter.Wait(context.Background()); err != nil {
+			return posts, err
+		}
+
+		found, err := GetPostsByIDDanbooru(site.URL, batch, site.Credentials, site.Client)
+		if err != nil {
+			return posts, err
+		}
+		posts = append(posts, asPosts(found)...)
+	}
+
+	return posts, nil
+}
+
+// Retrieves a pool or a favorite group
+func GetCollectionDanbooru(danbooruURL url.URL, kind CollectionKind, id int64, credentials Credentials, client *http.Client) (*DanbooruCollection, error) {
+	switch kind {
+	case CollectionPool:
+		danbooruURL.Path = fmt.Sprintf("/pools/%d.json", id)
+	case CollectionFavoriteGroup:
+		danbooruURL.Path = fmt.Sprintf("/favorite_groups/%d.json", id)
+	default:
+		return nil, fmt.Errorf("%s: %w", kind, ErrNotSupported)
+	}
+	danbooruURL.RawQuery = ""
+
+	data, err := proxy.GetContentsWithHeaders(client, danbooruURL.String(), credentials.basicAuthHeaders())
+	if err != nil {
+		return nil, err
+	}
+
+	var collection DanbooruCollection
+	err = json.Unmarshal(data, &collection)
+	if err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
+
+// Retrieves posts with given IDs with a single id:1,2,3 search
+func GetPostsByIDDanbooru(danbooruURL url.URL, ids []int64, credentials Credentials, client *http.Client) ([]DanbooruPost, error) {
+	idStrings := make([]string, len(ids))
+	for i, id := range ids {
+		idStrings[i] = strconv.FormatInt(id, 10)
+	}
+
+	query := danbooruURL.Query()
+	query.Set("limit", strconv.Itoa(len(ids)))
+	danbooruURL.RawQuery = query.Encode()
+
+	return GetPostsDanbooru(danbooruURL, PageCursor(1), "id:"+strings.Join(idStrings, ","), credentials, client)
+}
@@ -0,0 +1,122 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Tag categories of danbooru
+var danbooruTagCategories = map[int]string{
+	0: "general",
+	1: "artist",
+	3: "copyright",
+	4: "character",
+	5: "meta",
+}
+
+// Tag as listed by danbooru's and e621's /tags.json
+type DanbooruTag struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	PostCount    int    `json:"post_count"`
+	Category     int    `json:"category"`
+	IsDeprecated bool   `json:"is_deprecated"`
+}
+
+func (danbooruProvider) SearchTags(site *Site, prefix string, limit int) ([]TagInfo, error) {
+	tags, err := SearchTagsDanbooru(site.URL, prefix, limit, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return danbooruTagInfos(tags, danbooruTagCategories), nil
+}
+
+func (danbooruProvider) LookupTag(site *Site, name string) (*TagInfo, error) {
+	tags, err := LookupTagDanbooru(site.URL, name, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return findTagInfo(danbooruTagInfos(tags, danbooruTagCategories), name), nil
+}
+
+func danbooruTagInfos(tags []DanbooruTag, categories map[int]string) []TagInfo {
+	infos := make([]TagInfo, len(tags))
+	for i, tag := range tags {
+		infos[i] = TagInfo{
+			Name:      tag.Name,
+			Category:  categoryName(categories, tag.Category),
+			PostCount: tag.PostCount,
+		}
+	}
+
+	return infos
+}
+
+// Retrieves at most limit used tags starting with prefix, most used first
+func SearchTagsDanbooru(danbooruURL url.URL, prefix string, limit int, credentials Credentials, client *http.Client) ([]DanbooruTag, error) {
+	query := url.Values{}
+	query.Set("search[name_matches]", prefix+"*")
+	query.Set("search[order]", "count")
+	query.Set("search[hide_empty]", "true")
+	query.Set("limit", fmt.Sprintf("%d", limit))
+	danbooruURL.RawQuery = query.Encode()
+	danbooruURL.Path = "/tags.json"
+
+	data, err := proxy.GetContentsWithHeaders(client, danbooruURL.String(), credentials.basicAuthHeaders())
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []DanbooruTag
+	err = json.Unmarshal(data, &tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// Retrieves the tag named name, if there is one
+func LookupTagDanbooru(danbooruURL url.URL, name string, credentials Credentials, client *http.Client) ([]DanbooruTag, error) {
+	query := url.Values{}
+	query.Set("search[name]", name)
+	query.Set("limit", "1")
+	danbooruURL.RawQuery = query.Encode()
+	danbooruURL.Path = "/tags.json"
+
+	data, err := proxy.GetContentsWithHeaders(client, danbooruURL.String(), credentials.basicAuthHeaders())
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []DanbooruTag
+	err = json.Unmarshal(data, &tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,65 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"fmt"
+	"time"
+)
+
+// Implemented by providers of boorus able to search for posts by upload time
+type DateSearchProvider interface {
+	// Returns tags narrowed down to posts uploaded since since and before until, either of
+	// which may be zero. Results may hold posts uploaded a little outside of the bounds
+	WithDates(site *Site, tags string, since time.Time, until time.Time) string
+}
+
+// Reports whether the booru searches for posts by upload time
+func (site *Site) SearchesDates() bool {
+	_, ok := site.Provider.(DateSearchProvider)
+	return ok
+}
+
+// Returns tags narrowed down to posts uploaded within since and until, tags as is if
+// the booru can not search by upload time
+func (site *Site) WithDates(tags string, since time.Time, until time.Time) string {
+	provider, ok := site.Provider.(DateSearchProvider)
+	if !ok || (since.IsZero() && until.IsZero()) {
+		return tags
+	}
+
+	return provider.WithDates(site, tags, since, until)
+}
+
+// Returns a date:<from>..<to> metatag of danbooru-like boorus for posts uploaded within since
+// and until. These take days in their own time zone, so a day is added on both sides
+func dateMetatag(since time.Time, until time.Time) string {
+	const layout = "2006-01-02"
+
+	from := since.UTC().AddDate(0, 0, -1).Format(layout)
+	to := until.UTC().AddDate(0, 0, 1).Format(layout)
+	switch {
+	case until.IsZero():
+		return "date:>=" + from
+	case since.IsZero():
+		return "date:<=" + to
+	default:
+		return fmt.Sprintf("date:%s..%s", from, to)
+	}
+}
@@ -0,0 +1,452 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// e621 allows 2 API requests per second at most and asks to stay at 1 for sustained use
+var e621Limiter = rate.NewLimiter(rate.Every(time.Second), 1)
+
+func init() {
+	Register(e621Provider{})
+}
+
+type e621Provider struct{}
+
+func (e621Provider) Name() string {
+	return "e621"
+}
+
+func (e621Provider) Claims(booruURL url.URL) bool {
+	return isHostOf(booruURL.Hostname(), "e621.net") || isHostOf(booruURL.Hostname(), "e926.net")
+}
+
+func (e621Provider) Probe(site *Site) bool {
+	probeURL := site.URL
+	probeURL.Path = "/posts.json"
+	probeURL.RawQuery = "limit=1"
+
+	var galleryData struct {
+		Posts []json.RawMessage `json:"posts"`
+	}
+	return probeJSON(site.Client, probeURL, site.Credentials.basicAuthHeaders(), &galleryData) && galleryData.Posts != nil
+}
+
+func (e621Provider) GetPosts(site *Site, cursor Cursor, tags string) ([]Post, error) {
+	posts, err := GetPostsE621(site.URL, cursor, tags, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return asPosts(posts), nil
+}
+
+func (e621Provider) OrderedByID(site *Site, tags string) bool {
+	return !hasCustomOrder(tags)
+}
+
+func (e621Provider) ParsePostURL(postURL url.URL) (int64, bool) {
+	// Same as danbooru's
+	return parsePostPath(postURL, danbooruPostPath)
+}
+
+// e621 searches for 40 tags at once
+func (e621Provider) TagLimit(site *Site) int {
+	return 40
+}
+
+func (e621Provider) OrSyntax(site *Site) OrSyntax {
+	return OrTilde
+}
+
+func (e621Provider) WithDates(site *Site, tags string, since time.Time, until time.Time) string {
+	return strings.TrimSpace(tags + " " + dateMetatag(since, until))
+}
+
+// e621 can not order by favoriting time
+func (e621Provider) FavoritesQuery(site *Site, user string) (string, bool) {
+	return "fav:" + user, false
+}
+
+type E621Post struct {
+	chosenVariant
+	MediaHash     string
+	Host          string
+	PageURL       string
+	ID            int64             `json:"id"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	File          E621File          `json:"file"`
+	Preview       E621Preview       `json:"preview"`
+	Sample        E621Sample        `json:"sample"`
+	Score         E621Score         `json:"score"`
+	PostTags      E621Tags          `json:"tags"`
+	LockedTags    []string          `json:"locked_tags"`
+	ChangeSeq     int64             `json:"change_seq"`
+	Flags         E621Flags         `json:"flags"`
+	Rating        string            `json:"rating"`
+	FavCount      int               `json:"fav_count"`
+	Sources       []string          `json:"sources"`
+	Pools         []int64           `json:"pools"`
+	Relationships E621Relationships `json:"relationships"`
+	ApproverID    *int64            `json:"approver_id"`
+	UploaderID    int64             `json:"uploader_id"`
+	Description   string            `json:"description"`
+	CommentCount  int               `json:"comment_count"`
+	IsFavorited   bool              `json:"is_favorited"`
+	HasNotes      bool              `json:"has_notes"`
+	Duration      *float64          `json:"duration"`
+}
+
+type E621File struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Ext    string `json:"ext"`
+	Size   uint64 `json:"size"`
+	MD5    string `json:"md5"`
+	URL    string `json:"url"`
+}
+
+type E621Preview struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url"`
+}
+
+type E621Sample struct {
+	Has    bool   `json:"has"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url"`
+}
+
+type E621Score struct {
+	Up    int `json:"up"`
+	Down  int `json:"down"`
+	Total int `json:"total"`
+}
+
+type E621Tags struct {
+	General     []string `json:"general"`
+	Artist      []string `json:"artist"`
+	Contributor []string `json:"contributor"`
+	Copyright   []string `json:"copyright"`
+	Character   []string `json:"character"`
+	Species     []string `json:"species"`
+	Invalid     []string `json:"invalid"`
+	Meta        []string `json:"meta"`
+	Lore        []string `json:"lore"`
+}
+
+type E621Flags struct {
+	Pending      bool `json:"pending"`
+	Flagged      bool `json:"flagged"`
+	NoteLocked   bool `json:"note_locked"`
+	StatusLocked bool `json:"status_locked"`
+	RatingLocked bool `json:"rating_locked"`
+	Deleted      bool `json:"deleted"`
+}
+
+type E621Relationships struct {
+	ParentID          *int64  `json:"parent_id"`
+	HasChildren       bool    `json:"has_children"`
+	HasActiveChildren bool    `json:"has_active_children"`
+	Children          []int64 `json:"children"`
+}
+
+type E621JSONData struct {
+	Posts []E621Post `json:"posts"`
+}
+
+// Retrieves a page of posts. Login and API key, if given, are sent via HTTP basic authorization
+func GetPostsE621(e621URL url.URL, cursor Cursor, tags string, credentials Credentials, client *http.Client) ([]E621Post, error) {
+	query := e621URL.Query()
+	if cursor.BeforeID != 0 {
+		query.Set("page", fmt.Sprintf("b%d", cursor.BeforeID))
+	} else {
+		query.Set("page", fmt.Sprintf("%d", cursor.pageNumber()))
+	}
+
+	if tags != "" {
+		query.Set("tags", tags)
+	}
+	e621URL.RawQuery = query.Encode()
+	e621URL.Path = "/posts.json"
+
+	// Respect e621's own rate limits
+	if err := e621Limiter.Wait(context.Background()); err != nil {
+		return nil, err
+	}
+
+	data, err := proxy.GetContentsWithHeaders(client, e621URL.String(), credentials.basicAuthHeaders())
+	if err != nil {
+		return nil, err
+	}
+
+	var galleryData E621JSONData
+	err = json.Unmarshal(data, &galleryData)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range galleryData.Posts {
+		galleryData.Posts[i].Host = e621URL.Hostname()
+		galleryData.Posts[i].PageURL = pageURL(e621URL, fmt.Sprintf("/posts/%d", galleryData.Posts[i].ID), "")
+	}
+
+	return galleryData.Posts, nil
+}
+
+func (post *E621Post) PostID() int64 {
+	return post.ID
+}
+
+func (post *E621Post) ParentPostID() int64 {
+	if post.Relationships.ParentID == nil {
+		return 0
+	}
+
+	return *post.Relationships.ParentID
+}
+
+func (post *E621Post) HasChildPosts() bool {
+	return post.Relationships.HasChildren
+}
+
+// e621 lists children itself, but found ones are more up to date
+func (post *E621Post) SetChildPostIDs(ids []int64) {
+	post.Relationships.Children = ids
+}
+
+func (post *E621Post) Tags() []string {
+	return append(append([]string{}, post.PostTags.General...), post.PostTags.Invalid...)
+}
+
+func (post *E621Post) Copyright() []string {
+	return post.PostTags.Copyright
+}
+
+func (post *E621Post) Artists() []string {
+	return append(append([]string{}, post.PostTags.Artist...), post.PostTags.Contributor...)
+}
+
+func (post *E621Post) Characters() []string {
+	return post.PostTags.Character
+}
+
+func (post *E621Post) Species() []string {
+	return post.PostTags.Species
+}
+
+func (post *E621Post) Lore() []string {
+	return post.PostTags.Lore
+}
+
+func (post *E621Post) Meta() []string {
+	return post.PostTags.Meta
+}
+
+func (post *E621Post) FileExtension() string {
+	return post.File.Ext
+}
+
+func (post *E621Post) MediaURL() string {
+	if post.variant != nil {
+		return post.variant.URL
+	}
+
+	return post.originalURL()
+}
+
+func (post *E621Post) originalURL() string {
+	if post.File.URL == "" && post.File.MD5 != "" {
+		// Anonymous requests get no file URL for some posts, but the
+		// static file location is derived from md5 and is still reachable
+		return fmt.Sprintf(
+			"https://static1.%s/data/%s/%s/%s.%s",
+			post.Host,
+			post.File.MD5[0:2],
+			post.File.MD5[2:4],
+			post.File.MD5,
+			post.File.Ext,
+		)
+	}
+
+	return post.File.URL
+}
+
+func (post *E621Post) SaveMedia(directory string, client *http.Client) error {
+	mediaHash, size, err := saveMedia(post.MediaURL(), directory, client)
+	if err != nil {
+		return err
+	}
+	post.MediaHash = mediaHash
+	post.variantSize = size
+
+	return nil
+}
+
+func (post *E621Post) Variants() []Variant {
+	variants := []Variant{
+		{
+			Type:   VariantPreview,
+			URL:    post.Preview.URL,
+			Width:  post.Preview.Width,
+			Height: post.Preview.Height,
+		},
+		{
+			Type:    VariantOriginal,
+			URL:     post.originalURL(),
+			Width:   post.File.Width,
+			Height:  post.File.Height,
+			FileExt: post.File.Ext,
+		},
+	}
+
+	if post.Sample.Has {
+		variants = append(variants, Variant{
+			Type:   VariantSample,
+			URL:    post.Sample.URL,
+			Width:  post.Sample.Width,
+			Height: post.Sample.Height,
+		})
+	}
+
+	return variants
+}
+
+func (post *E621Post) SaveMetadata(directory string) error {
+	return saveMetadata(post.Metadata(), directory)
+}
+
+func (post *E621Post) IsImage() bool {
+	return isImageExtension(post.FileExtension())
+}
+
+func (post *E621Post) IsVideo() bool {
+	return (post.Duration != nil && *post.Duration > 0.0) || isVideoExtension(post.FileExtension())
+}
+
+func (post *E621Post) Size() uint64 {
+	if post.variant != nil {
+		return post.variantSize
+	}
+
+	return post.File.Size
+}
+
+func (post *E621Post) PostRating() Rating {
+	return parseRating(post.Rating, safeRatingLetters)
+}
+
+func (post *E621Post) CreatedTime() time.Time {
+	return post.CreatedAt
+}
+
+func (post *E621Post) Metadata() *Metadata {
+	var source string
+	if len(post.Sources) > 0 {
+		source = post.Sources[0]
+	}
+
+	var duration float64
+	if post.Duration != nil {
+		duration = *post.Duration
+	}
+
+	return &Metadata{
+		ID:         post.ID,
+		PostURL:    post.PageURL,
+		Tags:       post.Tags(),
+		Copyright:  post.Copyright(),
+		Characters: post.Characters(),
+		Artists:    post.Artists(),
+		Species:    post.Species(),
+		Lore:       post.Lore(),
+		Meta:       post.Meta(),
+		Rating:     post.PostRating(),
+		Score:      post.Score.Total,
+		FavCount:   reportedCount(post.FavCount),
+		Width:      post.File.Width,
+		Height:     post.File.Height,
+		Duration:   duration,
+		MD5:        post.File.MD5,
+		Source:     source,
+		CreatedAt:  formatTime(post.CreatedTime()),
+		Hash:       post.MediaHash,
+		FromHost:   post.Host,
+		URL:        post.MediaURL(),
+		Size:       post.Size(),
+		Relations:  relationsOf(post.ParentPostID(), post.HasChildPosts(), post.Relationships.Children),
+		Variant:    post.variantType(),
+	}
+}
+
+// Tag categories of e621
+var e621TagCategories = map[int]string{
+	0: "general",
+	1: "artist",
+	2: "contributor",
+	3: "copyright",
+	4: "character",
+	5: "species",
+	6: "invalid",
+	7: "meta",
+	8: "lore",
+}
+
+func (e621Provider) SearchTags(site *Site, prefix string, limit int) ([]TagInfo, error) {
+	// Same API as danbooru's, but requests are limited
+	err := e621Limiter.Wait(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := SearchTagsDanbooru(site.URL, prefix, limit, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return danbooruTagInfos(tags, e621TagCategories), nil
+}
+
+func (e621Provider) LookupTag(site *Site, name string) (*TagInfo, error) {
+	err := e621Limiter.Wait(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := LookupTagDanbooru(site.URL, name, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return findTagInfo(danbooruTagInfos(tags, e621TagCategories), name), nil
+}
@@ -19,56 +19,183 @@
 package booru
 
 import (
+	"Unbewohnte/gobooru-downloader/internal/logger"
 	"Unbewohnte/gobooru-downloader/internal/proxy"
-	"crypto/sha256"
-	"encoding/hex"
+	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
+func init() {
+	Register(gelbooruProvider{})
+}
+
+// Serves gelbooru.com and any other Gelbooru 0.2 compatible booru
+type gelbooruProvider struct{}
+
+func (gelbooruProvider) Name() string {
+	return "gelbooru"
+}
+
+func (gelbooruProvider) Claims(booruURL url.URL) bool {
+	switch booruURL.Hostname() {
+	case "gelbooru.com", "safebooru.org", "rule34.xxx", "hypnohub.net", "xbooru.com", "tbib.org", "realbooru.com":
+		return true
+	default:
+		// Self-hosted instances are recognized by their index.php entry point
+		return strings.HasSuffix(booruURL.Path, "index.php")
+	}
+}
+
+func (gelbooruProvider) Probe(site *Site) bool {
+	probeURL := gelbooruAPIURL(site.URL)
+	query := url.Values{}
+	query.Set("page", "dapi")
+	query.Set("s", "post")
+	query.Set("q", "index")
+	query.Set("limit", "1")
+	setGelbooruCredentials(query, site.Credentials)
+	probeURL.RawQuery = query.Encode()
+
+	data, err := proxy.GetContents(site.Client, probeURL.String())
+	if err != nil {
+		return false
+	}
+
+	_, err = parseGelbooruPosts(data)
+	return err == nil && len(bytes.TrimSpace(data)) != 0
+}
+
+func (gelbooruProvider) OrderedByID(site *Site, tags string) bool {
+	return !hasCustomOrder(tags)
+}
+
+// Post pages look like index.php?page=post&s=view&id=<id>
+func (gelbooruProvider) ParsePostURL(postURL url.URL) (int64, bool) {
+	query := postURL.Query()
+	if query.Get("page") != "post" || query.Get("s") != "view" {
+		return 0, false
+	}
+
+	id, err := strconv.ParseInt(query.Get("id"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return id, true
+}
+
+// Gelbooru does not limit the amount of tags in a search
+func (gelbooruProvider) TagLimit(site *Site) int {
+	return 0
+}
+
+func (gelbooruProvider) OrSyntax(site *Site) OrSyntax {
+	return OrBraces
+}
+
+// Favorites are searched by user ID and can not be ordered by favoriting time
+func (gelbooruProvider) FavoritesQuery(site *Site, user string) (string, bool) {
+	return "fav:" + user, false
+}
+
+func (gelbooruProvider) GetPosts(site *Site, cursor Cursor, tags string) ([]Post, error) {
+	posts, err := GetPostsGelbooru(site.URL, cursor, tags, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve tag types of the whole page at once
+	cache := getGelbooruTagCache(site.URL.Hostname())
+	var pageTags []string
+	for _, post := range posts {
+		pageTags = append(pageTags, strings.Fields(post.PostTags)...)
+	}
+	err = cache.resolve(site, pageTags)
+	if err != nil {
+		logger.Warning("[Gelbooru] Failed to look up tag types, some tags will not be categorized: %s", err)
+	}
+
+	for i := range posts {
+		posts[i].tagCache = cache
+	}
+
+	return asPosts(posts), nil
+}
+
 type Attributes struct {
 	Limit  int `json:"limit"`
 	Offset int `json:"offset"`
 	Count  int `json:"count"`
 }
 
+// Gelbooru 0.2 forks disagree on whether some fields are strings,
+// numbers or booleans, so any of them is accepted as a string
+type flexString string
+
+func (s *flexString) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = flexString(str)
+		return nil
+	}
+
+	if string(data) == "null" {
+		*s = ""
+		return nil
+	}
+
+	*s = flexString(data)
+	return nil
+}
+
 type GelbooruPost struct {
+	chosenVariant
 	MediaHash     string
 	FileSize      uint64
-	ID            int    `json:"id"`
-	CreatedAt     string `json:"created_at"`
-	Score         int    `json:"score"`
-	Width         int    `json:"width"`
-	Height        int    `json:"height"`
-	MD5           string `json:"md5"`
-	Directory     string `json:"directory"`
-	Image         string `json:"image"`
-	Rating        string `json:"rating"`
-	Source        string `json:"source"`
-	Change        int64  `json:"change"`
-	Owner         string `json:"owner"`
-	CreatorID     int    `json:"creator_id"`
-	ParentID      int    `json:"parent_id"`
-	Sample        int    `json:"sample"`
-	PreviewHeight int    `json:"preview_height"`
-	PreviewWidth  int    `json:"preview_width"`
-	PostTags      string `json:"tags"`
-	Title         string `json:"title"`
-	HasNotes      string `json:"has_notes"`
-	HasComments   string `json:"has_comments"`
-	FileURL       string `json:"file_url"`
-	PreviewURL    string `json:"preview_url"`
-	SampleURL     string `json:"sample_url"`
-	SampleHeight  int    `json:"sample_height"`
-	SampleWidth   int    `json:"sample_width"`
-	Status        string `json:"status"`
-	PostLocked    int    `json:"post_locked"`
-	HasChildren   string `json:"has_children"`
+	Host          string
+	PageURL       string
+	BaseURL       url.URL
+	tagCache      *gelbooruTagCache
+	ChildIDs      []int64
+	ID            int        `json:"id"`
+	CreatedAt     string     `json:"created_at"`
+	Score         int        `json:"score"`
+	Width         int        `json:"width"`
+	Height        int        `json:"height"`
+	MD5           string     `json:"md5"`
+	Hash          string     `json:"hash"`
+	Directory     flexString `json:"directory"`
+	Image         string     `json:"image"`
+	Rating        string     `json:"rating"`
+	Source        string     `json:"source"`
+	Change        int64      `json:"change"`
+	Owner         string     `json:"owner"`
+	CreatorID     int        `json:"creator_id"`
+	ParentID      int        `json:"parent_id"`
+	Sample        flexString `json:"sample"`
+	PreviewHeight int        `json:"preview_height"`
+	PreviewWidth  int        `json:"preview_width"`
+	PostTags      string     `json:"tags"`
+	Title         string     `json:"title"`
+	HasNotes      flexString `json:"has_notes"`
+	HasComments   flexString `json:"has_comments"`
+	CommentCount  int        `json:"comment_count"`
+	FileURL       string     `json:"file_url"`
+	PreviewURL    string     `json:"preview_url"`
+	SampleURL     string     `json:"sample_url"`
+	SampleHeight  int        `json:"sample_height"`
+	SampleWidth   int        `json:"sample_width"`
+	Status        string     `json:"status"`
+	PostLocked    int        `json:"post_locked"`
+	HasChildren   flexString `json:"has_children"`
 }
 
 type GelbooruJSONData struct {
@@ -76,167 +203,386 @@ type GelbooruJSONData struct {
 	Posts      []GelbooruPost `json:"post"`
 }
 
-func GetPostsGelbooru(gelbooruURL url.URL, page uint, tags string, client *http.Client) ([]GelbooruPost, error) {
+// Gelbooru 0.2 XML describes entries either with attributes or with
+// child elements, so both are collected into a single name -> value map
+type gelbooruXMLNode struct {
+	Attributes []xml.Attr `xml:",any,attr"`
+	Children   []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+func (node gelbooruXMLNode) fields() map[string]string {
+	fields := make(map[string]string, len(node.Attributes)+len(node.Children))
+	for _, attribute := range node.Attributes {
+		fields[attribute.Name.Local] = attribute.Value
+	}
+	for _, child := range node.Children {
+		fields[child.XMLName.Local] = child.Value
+	}
+
+	return fields
+}
+
+type gelbooruXMLData struct {
+	XMLName xml.Name          `xml:"posts"`
+	Posts   []gelbooruXMLNode `xml:"post"`
+}
+
+func gelbooruPostFromFields(fields map[string]string) GelbooruPost {
+	atoi := func(key string) int {
+		number, _ := strconv.Atoi(fields[key])
+		return number
+	}
+	change, _ := strconv.ParseInt(fields["change"], 10, 64)
+
+	return GelbooruPost{
+		ID:            atoi("id"),
+		CreatedAt:     fields["created_at"],
+		Score:         atoi("score"),
+		Width:         atoi("width"),
+		Height:        atoi("height"),
+		MD5:           fields["md5"],
+		Hash:          fields["hash"],
+		Directory:     flexString(fields["directory"]),
+		Image:         fields["image"],
+		Rating:        fields["rating"],
+		Source:        fields["source"],
+		Change:        change,
+		Owner:         fields["owner"],
+		CreatorID:     atoi("creator_id"),
+		ParentID:      atoi("parent_id"),
+		Sample:        flexString(fields["sample"]),
+		PreviewHeight: atoi("preview_height"),
+		PreviewWidth:  atoi("preview_width"),
+		PostTags:      fields["tags"],
+		Title:         fields["title"],
+		HasNotes:      flexString(fields["has_notes"]),
+		HasComments:   flexString(fields["has_comments"]),
+		CommentCount:  atoi("comment_count"),
+		FileURL:       fields["file_url"],
+		PreviewURL:    fields["preview_url"],
+		SampleURL:     fields["sample_url"],
+		SampleHeight:  atoi("sample_height"),
+		SampleWidth:   atoi("sample_width"),
+		Status:        fields["status"],
+		PostLocked:    atoi("post_locked"),
+		HasChildren:   flexString(fields["has_children"]),
+	}
+}
+
+// Parses posts out of any response shape Gelbooru 0.2 compatible boorus produce:
+// gelbooru.com's JSON object, a bare JSON array or XML
+func parseGelbooruPosts(data []byte) ([]GelbooruPost, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		// Some forks answer with an empty body when nothing was found
+		return nil, nil
+	}
+
+	switch data[0] {
+	case '[':
+		var posts []GelbooruPost
+		err := json.Unmarshal(data, &posts)
+		if err != nil {
+			return nil, err
+		}
+		return posts, nil
+
+	case '{':
+		var galleryData GelbooruJSONData
+		err := json.Unmarshal(data, &galleryData)
+		if err != nil {
+			return nil, err
+		}
+		return galleryData.Posts, nil
+
+	case '<':
+		var xmlData gelbooruXMLData
+		err := xml.Unmarshal(data, &xmlData)
+		if err != nil {
+			return nil, err
+		}
+
+		posts := make([]GelbooruPost, len(xmlData.Posts))
+		for i, node := range xmlData.Posts {
+			posts[i] = gelbooruPostFromFields(node.fields())
+		}
+		return posts, nil
+
+	default:
+		return nil, fmt.Errorf("unrecognized gelbooru response: %.32q", data)
+	}
+}
+
+// Returns URL of the API endpoint. Self-hosted instances may live in a
+// subdirectory, so an explicitly given index.php path is kept
+func gelbooruAPIURL(gelbooruURL url.URL) url.URL {
+	if !strings.HasSuffix(gelbooruURL.Path, "index.php") {
+		gelbooruURL.Path = "/index.php"
+	}
+
+	return gelbooruURL
+}
+
+// Gelbooru accepts credentials only as query parameters
+func setGelbooruCredentials(query url.Values, credentials Credentials) {
+	if credentials.APIKey != "" && credentials.UserID != "" {
+		query.Set("api_key", credentials.APIKey)
+		query.Set("user_id", credentials.UserID)
+	}
+}
+
+// Retrieves a page of posts. API key and user ID, if given, are sent as query parameters
+func GetPostsGelbooru(gelbooruURL url.URL, cursor Cursor, tags string, credentials Credentials, client *http.Client) ([]GelbooruPost, error) {
+	gelbooruURL = gelbooruAPIURL(gelbooruURL)
+
 	query := gelbooruURL.Query()
+	setGelbooruCredentials(query, credentials)
 	query.Set("page", "dapi")
 	query.Set("s", "post")
 	query.Set("q", "index")
 	query.Set("json", "1")
 
-	if page == 0 {
-		page = 1
+	if cursor.BeforeID != 0 {
+		// Continue with an ID range, deep pid offsets are refused
+		tags = strings.TrimSpace(fmt.Sprintf("%s id:<%d", tags, cursor.BeforeID))
+		query.Set("pid", "0")
+	} else {
+		// pid is zero-based
+		query.Set("pid", fmt.Sprintf("%d", cursor.pageNumber()-1))
 	}
-	query.Set("pid", fmt.Sprintf("%d", page))
 
 	if tags != "" {
 		query.Set("tags", tags)
 	}
 	gelbooruURL.RawQuery = query.Encode()
-	gelbooruURL.Path = "/index.php"
 
 	data, err := proxy.GetContents(client, gelbooruURL.String())
 	if err != nil {
 		return nil, err
 	}
 
-	var galleryData GelbooruJSONData
-	err = json.Unmarshal(data, &galleryData)
+	posts, err := parseGelbooruPosts(data)
 	if err != nil {
 		return nil, err
 	}
 
-	return galleryData.Posts, nil
+	// Keep credentials out of posts
+	baseURL := gelbooruURL
+	baseURL.RawQuery = ""
+	for i := range posts {
+		posts[i].Host = gelbooruURL.Hostname()
+		posts[i].BaseURL = baseURL
+		posts[i].PageURL = pageURL(gelbooruURL, gelbooruURL.Path, fmt.Sprintf("page=post&s=view&id=%d", posts[i].ID))
+		posts[i].FileURL = resolveURL(gelbooruURL, posts[i].FileURL)
+		posts[i].SampleURL = resolveURL(gelbooruURL, posts[i].SampleURL)
+		posts[i].PreviewURL = resolveURL(gelbooruURL, posts[i].PreviewURL)
+	}
+
+	return posts, nil
+}
+
+func (post *GelbooruPost) PostID() int64 {
+	return int64(post.ID)
+}
+
+// Returns post tags of the given type. Without resolved tag
+// types every tag is considered general
+func (post *GelbooruPost) tagsOfType(tagType int) []string {
+	if post.tagCache == nil {
+		if tagType == gelbooruTagGeneral {
+			return strings.Fields(post.PostTags)
+		}
+		return nil
+	}
+
+	var result []string
+	for _, tag := range strings.Fields(post.PostTags) {
+		if post.tagCache.typeOf(tag) == tagType {
+			result = append(result, tag)
+		}
+	}
+
+	return result
+}
+
+func (post *GelbooruPost) ParentPostID() int64 {
+	return int64(post.ParentID)
+}
+
+func (post *GelbooruPost) HasChildPosts() bool {
+	return post.HasChildren == "true"
+}
+
+func (post *GelbooruPost) SetChildPostIDs(ids []int64) {
+	post.ChildIDs = ids
 }
 
 func (post *GelbooruPost) Tags() []string {
-	return strings.Fields(post.PostTags)
+	return post.tagsOfType(gelbooruTagGeneral)
 }
 
 func (post *GelbooruPost) Copyright() []string {
-	return nil
+	return post.tagsOfType(gelbooruTagCopyright)
 }
 
 func (post *GelbooruPost) Meta() []string {
-	return nil
+	return post.tagsOfType(gelbooruTagMetadata)
 }
 
 func (post *GelbooruPost) Artists() []string {
-	return nil
+	return post.tagsOfType(gelbooruTagArtist)
 }
 
 func (post *GelbooruPost) Characters() []string {
-	return nil
+	return post.tagsOfType(gelbooruTagCharacter)
 }
 
 func (post *GelbooruPost) FileExtension() string {
-	return filepath.Ext(post.FileURL)
+	return filepath.Ext(post.MediaURL())
 }
 
 func (post *GelbooruPost) MediaURL() string {
-	return post.FileURL
-}
-
-func (post *GelbooruPost) SaveMedia(directory string, client *http.Client) error {
-	// Get file contents
-	contents, err := proxy.GetContents(client, post.MediaURL())
-	if err != nil {
-		return err
+	if post.variant != nil {
+		return post.variant.URL
 	}
 
-	// Calculate hash
-	hasher := sha256.New()
-	hasher.Write(contents)
-	mediaHash := hex.EncodeToString(hasher.Sum(nil))
-	post.MediaHash = mediaHash
-
-	fileExt := filepath.Ext(post.MediaURL())
-
-	// Save media
-	path := filepath.Join(directory, mediaHash+fileExt)
+	return post.originalURL()
+}
 
-	file, err := os.Create(path)
-	if err != nil {
-		return err
+func (post *GelbooruPost) originalURL() string {
+	if post.FileURL == "" && post.Image != "" {
+		// Some forks (safebooru.org) leave out file URLs, but
+		// originals are always stored under images/<directory>/<image>
+		return resolveURL(
+			post.BaseURL,
+			fmt.Sprintf("images/%s/%s", post.Directory, post.Image),
+		)
 	}
-	defer file.Close()
 
-	_, err = file.Write(contents)
+	return post.FileURL
+}
+
+func (post *GelbooruPost) SaveMedia(directory string, client *http.Client) error {
+	mediaHash, size, err := saveMedia(post.MediaURL(), directory, client)
 	if err != nil {
 		return err
 	}
+	post.MediaHash = mediaHash
 
 	// Remember file size
-	post.FileSize = uint64(len(contents))
+	if post.variant != nil {
+		post.variantSize = size
+	} else {
+		post.FileSize = size
+	}
 
 	return nil
 }
 
-func (post *GelbooruPost) SaveMetadata(directory string) error {
-	file, err := os.Create(
-		filepath.Join(
-			directory,
-			fmt.Sprintf("%s_metadata.json", post.MediaHash),
-		),
-	)
-	if err != nil {
-		return err
+func (post *GelbooruPost) Variants() []Variant {
+	variants := []Variant{
+		{
+			Type:   VariantPreview,
+			URL:    post.PreviewURL,
+			Width:  post.PreviewWidth,
+			Height: post.PreviewHeight,
+		},
+		{
+			Type:    VariantOriginal,
+			URL:     post.originalURL(),
+			Width:   post.Width,
+			Height:  post.Height,
+			FileExt: post.FileExtension(),
+		},
 	}
-	defer file.Close()
 
-	contents, err := json.Marshal(post.Metadata())
-	if err != nil {
-		return err
+	// Posts without a sample point sample URL at the original
+	if post.SampleURL != "" && post.SampleURL != post.FileURL {
+		variants = append(variants, Variant{
+			Type:   VariantSample,
+			URL:    post.SampleURL,
+			Width:  post.SampleWidth,
+			Height: post.SampleHeight,
+		})
 	}
 
-	_, err = file.Write(contents)
-	if err != nil {
-		return err
-	}
+	return variants
+}
 
-	return nil
+func (post *GelbooruPost) SaveMetadata(directory string) error {
+	return saveMetadata(post.Metadata(), directory)
 }
 
 func (post *GelbooruPost) IsImage() bool {
-	imageExtensions := map[string]bool{
-		"jpg":  true,
-		"jpeg": true,
-		"png":  true,
-		"gif":  true,
-		"webp": true,
-		"bmp":  true,
-		"tiff": true,
-	}
-
-	return imageExtensions[post.FileExtension()[1:]]
+	return isImageExtension(post.FileExtension())
 }
 
 func (post *GelbooruPost) IsVideo() bool {
-	videoExtensions := map[string]bool{
-		"mp4":  true,
-		"webm": true,
-		"avi":  true,
-		"mov":  true,
-		"mkv":  true,
-		"flv":  true,
-		"wmv":  true,
+	return isVideoExtension(post.FileExtension())
+}
+
+// Gelbooru.com names ratings in full, gelbooru 0.2 boorus may use letters
+func (post *GelbooruPost) PostRating() Rating {
+	return parseRating(post.Rating, safeRatingLetters)
+}
+
+// Layouts of dates used by gelbooru.com (e.g. "Sat Mar 02 12:34:56 -0600 2024") and Gelbooru 0.2 boorus
+var gelbooruTimeLayouts = []string{
+	time.RubyDate,
+	time.UnixDate,
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// Parses a date given by gelbooru, returns zero time if the date is not recognized
+func parseGelbooruTime(value string) time.Time {
+	for _, layout := range gelbooruTimeLayouts {
+		parsed, err := time.Parse(layout, strings.TrimSpace(value))
+		if err == nil {
+			return parsed
+		}
 	}
 
-	return videoExtensions[post.FileExtension()[1:]]
+	return time.Time{}
+}
+
+func (post *GelbooruPost) CreatedTime() time.Time {
+	return parseGelbooruTime(post.CreatedAt)
 }
 
 func (post *GelbooruPost) Metadata() *Metadata {
 	return &Metadata{
+		ID:         int64(post.ID),
+		PostURL:    post.PageURL,
 		Tags:       post.Tags(),
 		Copyright:  post.Copyright(),
 		Characters: post.Characters(),
 		Artists:    post.Artists(),
+		Meta:       post.Meta(),
+		Rating:     post.PostRating(),
+		Score:      post.Score,
+		Width:      post.Width,
+		Height:     post.Height,
+		MD5:        post.MD5,
+		Source:     firstSource(post.Source),
+		CreatedAt:  formatTime(post.CreatedTime()),
 		Hash:       post.MediaHash,
-		FromHost:   "gelbooru.com",
+		FromHost:   post.Host,
 		URL:        post.MediaURL(),
 		Size:       post.Size(),
+		Relations:  relationsOf(post.ParentPostID(), post.HasChildPosts(), post.ChildIDs),
+		Variant:    post.variantType(),
 	}
 }
 
 func (post *GelbooruPost) Size() uint64 {
+	if post.variant != nil {
+		return post.variantSize
+	}
+
 	return post.FileSize
 }
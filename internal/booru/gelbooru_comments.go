@@ -0,0 +1,147 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"golang.org/x/time/rate"
+)
+
+type GelbooruComment struct {
+	ID        int64
+	PostID    int64
+	Creator   string
+	CreatorID int64
+	CreatedAt string
+	Body      string
+}
+
+type gelbooruCommentXMLData struct {
+	XMLName  xml.Name          `xml:"comments"`
+	Comments []gelbooruXMLNode `xml:"comment"`
+}
+
+func (gelbooruProvider) GetComments(site *Site, post Post, limiter *rate.Limiter) (*PostComments, error) {
+	gelbooruPost, ok := post.(*GelbooruPost)
+	if !ok || (gelbooruPost.HasComments != "true" && gelbooruPost.CommentCount == 0) {
+		return nil, nil
+	}
+
+	if err := limiter.Wait(context.Background()); err != nil {
+		return nil, err
+	}
+
+	comments, err := GetCommentsGelbooru(site.URL, int64(gelbooruPost.ID), site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Threads are read oldest first
+	slices.SortFunc(comments, func(a, b GelbooruComment) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	postComments := &PostComments{PostID: int64(gelbooruPost.ID)}
+	for _, comment := range comments {
+		createdAt := comment.CreatedAt
+		if parsed := parseGelbooruTime(comment.CreatedAt); !parsed.IsZero() {
+			createdAt = formatTime(parsed)
+		}
+
+		postComments.Comments = append(postComments.Comments, Comment{
+			ID:        comment.ID,
+			Author:    comment.Creator,
+			AuthorID:  comment.CreatorID,
+			CreatedAt: createdAt,
+			Body:      comment.Body,
+		})
+	}
+
+	if len(postComments.Comments) == 0 {
+		return nil, nil
+	}
+
+	return postComments, nil
+}
+
+// Parses comments out of the comment API's XML
+func parseGelbooruComments(data []byte) ([]GelbooruComment, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	if data[0] != '<' {
+		return nil, fmt.Errorf("unrecognized gelbooru response: %.32q", data)
+	}
+
+	var xmlData gelbooruCommentXMLData
+	err := xml.Unmarshal(data, &xmlData)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := make([]GelbooruComment, len(xmlData.Comments))
+	for i, node := range xmlData.Comments {
+		fields := node.fields()
+		id, _ := strconv.ParseInt(fields["id"], 10, 64)
+		postID, _ := strconv.ParseInt(fields["post_id"], 10, 64)
+		creatorID, _ := strconv.ParseInt(fields["creator_id"], 10, 64)
+		comments[i] = GelbooruComment{
+			ID:        id,
+			PostID:    postID,
+			Creator:   fields["creator"],
+			CreatorID: creatorID,
+			CreatedAt: fields["created_at"],
+			Body:      fields["body"],
+		}
+	}
+
+	return comments, nil
+}
+
+// Retrieves comments of the post with given ID. The comment API only speaks XML
+func GetCommentsGelbooru(gelbooruURL url.URL, postID int64, credentials Credentials, client *http.Client) ([]GelbooruComment, error) {
+	commentURL := gelbooruAPIURL(gelbooruURL)
+
+	query := url.Values{}
+	query.Set("page", "dapi")
+	query.Set("s", "comment")
+	query.Set("q", "index")
+	query.Set("post_id", strconv.FormatInt(postID, 10))
+	setGelbooruCredentials(query, credentials)
+	commentURL.RawQuery = query.Encode()
+
+	data, err := proxy.GetContents(client, commentURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseGelbooruComments(data)
+}
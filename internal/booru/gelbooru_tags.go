@@ -0,0 +1,383 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/files"
+	"Unbewohnte/gobooru-downloader/internal/logger"
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Gelbooru tag types
+const (
+	gelbooruTagGeneral    int = 0
+	gelbooruTagArtist     int = 1
+	gelbooruTagCopyright  int = 3
+	gelbooruTagCharacter  int = 4
+	gelbooruTagMetadata   int = 5
+	gelbooruTagDeprecated int = 6
+)
+
+// Amount of tags looked up in a single request
+const gelbooruTagBatchSize int = 100
+
+type GelbooruTag struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Count     int        `json:"count"`
+	Type      flexString `json:"type"`
+	Ambiguous flexString `json:"ambiguous"`
+}
+
+type GelbooruTagJSONData struct {
+	Attributes Attributes    `json:"@attributes"`
+	Tags       []GelbooruTag `json:"tag"`
+}
+
+type gelbooruTagXMLData struct {
+	XMLName xml.Name          `xml:"tags"`
+	Tags    []gelbooruXMLNode `xml:"tag"`
+}
+
+// Returned for responses of the tag API which are not tags, as when it is missing
+var errUnrecognizedGelbooruResponse = errors.New("unrecognized gelbooru response")
+
+// Parses tags out of gelbooru.com's JSON object, a bare JSON array or XML
+func parseGelbooruTags(data []byte) ([]GelbooruTag, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	switch data[0] {
+	case '[':
+		var tags []GelbooruTag
+		err := json.Unmarshal(data, &tags)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errUnrecognizedGelbooruResponse, err)
+		}
+		return tags, nil
+
+	case '{':
+		var tagData GelbooruTagJSONData
+		err := json.Unmarshal(data, &tagData)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errUnrecognizedGelbooruResponse, err)
+		}
+		return tagData.Tags, nil
+
+	case '<':
+		var xmlData gelbooruTagXMLData
+		err := xml.Unmarshal(data, &xmlData)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errUnrecognizedGelbooruResponse, err)
+		}
+
+		tags := make([]GelbooruTag, len(xmlData.Tags))
+		for i, node := range xmlData.Tags {
+			fields := node.fields()
+			id, _ := strconv.Atoi(fields["id"])
+			count, _ := strconv.Atoi(fields["count"])
+			tags[i] = GelbooruTag{
+				ID:        id,
+				Name:      fields["name"],
+				Count:     count,
+				Type:      flexString(fields["type"]),
+				Ambiguous: flexString(fields["ambiguous"]),
+			}
+		}
+		return tags, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %.32q", errUnrecognizedGelbooruResponse, data)
+	}
+}
+
+// Tag name -> tag type cache of a single gelbooru host, persisted on disk
+type gelbooruTagCache struct {
+	mutex sync.RWMutex
+	path  string
+	types map[string]int
+	// Tags the booru did not know about during this run
+	missing map[string]bool
+	// Set once the booru refused lookups, it is not asked again during this run
+	disabled bool
+}
+
+var (
+	gelbooruTagCaches      = make(map[string]*gelbooruTagCache)
+	gelbooruTagCachesMutex sync.Mutex
+)
+
+// Returns tag type cache for host, loading it from disk on first use
+func getGelbooruTagCache(host string) *gelbooruTagCache {
+	gelbooruTagCachesMutex.Lock()
+	defer gelbooruTagCachesMutex.Unlock()
+
+	if cache, ok := gelbooruTagCaches[host]; ok {
+		return cache
+	}
+
+	cache := &gelbooruTagCache{
+		types:   make(map[string]int),
+		missing: make(map[string]bool),
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		logger.Warning("[Gelbooru] No cache directory, tag types will not be kept between runs: %s", err)
+	} else {
+		cache.path = filepath.Join(cacheDir, "gobooru-downloader", fmt.Sprintf("gelbooru_tags_%s.json", host))
+		contents, err := os.ReadFile(cache.path)
+		if err == nil {
+			err = json.Unmarshal(contents, &cache.types)
+			if err != nil {
+				logger.Warning("[Gelbooru] Ignoring broken tag cache %s: %s", cache.path, err)
+				cache.types = make(map[string]int)
+			}
+		}
+	}
+
+	gelbooruTagCaches[host] = cache
+	return cache
+}
+
+// Returns type of tag. Unknown tags are considered general
+func (cache *gelbooruTagCache) typeOf(tag string) int {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+
+	tagType, ok := cache.types[tag]
+	if !ok || tagType == gelbooruTagDeprecated {
+		return gelbooruTagGeneral
+	}
+
+	return tagType
+}
+
+// Reports whether lookups failed with err are never going to succeed during this run
+func gelbooruTagsUnavailable(err error) bool {
+	var statusErr *proxy.StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+			return true
+		}
+	}
+
+	return errors.Is(err, errUnrecognizedGelbooruResponse)
+}
+
+// Looks up types of tags not yet in cache via the tag API in batches, waiting for the
+// limiter of site before each, and saves the cache. Batches failing for a while are skipped
+// and looked up again with the next page, the booru refusing lookups disables them
+func (cache *gelbooruTagCache) resolve(site *Site, tags []string) error {
+	cache.mutex.RLock()
+	if cache.disabled {
+		cache.mutex.RUnlock()
+		return nil
+	}
+
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		_, known := cache.types[tag]
+		if !known && !cache.missing[tag] && !seen[tag] {
+			unknown = append(unknown, tag)
+			seen[tag] = true
+		}
+	}
+	cache.mutex.RUnlock()
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	var lookupErr error
+	for start := 0; start < len(unknown); start += gelbooruTagBatchSize {
+		batch := unknown[start:min(start+gelbooruTagBatchSize, len(unknown))]
+
+		err := site.wait()
+		if err != nil {
+			return err
+		}
+
+		resolved, err := getGelbooruTags(site, batch)
+		if err != nil && gelbooruTagsUnavailable(err) {
+			cache.mutex.Lock()
+			cache.disabled = true
+			cache.mutex.Unlock()
+			return err
+		}
+		if err != nil {
+			lookupErr = err
+			continue
+		}
+
+		cache.mutex.Lock()
+		found := make(map[string]bool, len(resolved))
+		for _, tag := range resolved {
+			tagType, err := strconv.Atoi(string(tag.Type))
+			if err != nil {
+				continue
+			}
+			cache.types[tag.Name] = tagType
+			found[tag.Name] = true
+		}
+		for _, tag := range batch {
+			if !found[tag] {
+				cache.missing[tag] = true
+			}
+		}
+		cache.mutex.Unlock()
+	}
+
+	err := cache.save()
+	if lookupErr != nil {
+		return lookupErr
+	}
+
+	return err
+}
+
+func (cache *gelbooruTagCache) save() error {
+	if cache.path == "" {
+		return nil
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	contents, err := json.Marshal(cache.types)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(cache.path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return files.WriteAtomic(cache.path, contents)
+}
+
+// Queries the tag API for the given tag names
+func getGelbooruTags(site *Site, names []string) ([]GelbooruTag, error) {
+	tagURL := gelbooruAPIURL(site.URL)
+
+	query := tagURL.Query()
+	query.Set("page", "dapi")
+	query.Set("s", "tag")
+	query.Set("q", "index")
+	query.Set("json", "1")
+	query.Set("limit", strconv.Itoa(len(names)))
+	query.Set("names", strings.Join(names, " "))
+	setGelbooruCredentials(query, site.Credentials)
+	tagURL.RawQuery = query.Encode()
+
+	data, err := proxy.GetContents(site.Client, tagURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseGelbooruTags(data)
+}
+
+// Gelbooru tag types as tag search categories
+var gelbooruTagCategories = map[int]string{
+	gelbooruTagGeneral:    "general",
+	gelbooruTagArtist:     "artist",
+	gelbooruTagCopyright:  "copyright",
+	gelbooruTagCharacter:  "character",
+	gelbooruTagMetadata:   "meta",
+	gelbooruTagDeprecated: "deprecated",
+}
+
+func (gelbooruProvider) SearchTags(site *Site, prefix string, limit int) ([]TagInfo, error) {
+	tags, err := SearchTagsGelbooru(site.URL, prefix, limit, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return gelbooruTagInfos(tags), nil
+}
+
+func (gelbooruProvider) LookupTag(site *Site, name string) (*TagInfo, error) {
+	tags, err := getGelbooruTags(site, []string{name})
+	if err != nil {
+		return nil, err
+	}
+
+	return findTagInfo(gelbooruTagInfos(tags), name), nil
+}
+
+func gelbooruTagInfos(tags []GelbooruTag) []TagInfo {
+	infos := make([]TagInfo, 0, len(tags))
+	for _, tag := range tags {
+		tagType, err := strconv.Atoi(string(tag.Type))
+		if err != nil {
+			tagType = gelbooruTagGeneral
+		}
+
+		infos = append(infos, TagInfo{
+			Name:      tag.Name,
+			Category:  categoryName(gelbooruTagCategories, tagType),
+			PostCount: tag.Count,
+		})
+	}
+
+	return infos
+}
+
+// Retrieves at most limit tags starting with prefix, most used first
+func SearchTagsGelbooru(gelbooruURL url.URL, prefix string, limit int, credentials Credentials, client *http.Client) ([]GelbooruTag, error) {
+	tagURL := gelbooruAPIURL(gelbooruURL)
+
+	query := url.Values{}
+	query.Set("page", "dapi")
+	query.Set("s", "tag")
+	query.Set("q", "index")
+	query.Set("json", "1")
+	query.Set("limit", strconv.Itoa(limit))
+	// % is the wildcard of name patterns
+	query.Set("name_pattern", prefix+"%")
+	query.Set("orderby", "count")
+	query.Set("order", "desc")
+	setGelbooruCredentials(query, credentials)
+	tagURL.RawQuery = query.Encode()
+
+	data, err := proxy.GetContents(client, tagURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseGelbooruTags(data)
+}
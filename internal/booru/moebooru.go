@@ -0,0 +1,484 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register(moebooruProvider{})
+}
+
+type moebooruProvider struct{}
+
+func (moebooruProvider) Name() string {
+	return "moebooru"
+}
+
+func (moebooruProvider) Claims(booruURL url.URL) bool {
+	switch booruURL.Hostname() {
+	case "yande.re", "konachan.com", "konachan.net":
+		return true
+	default:
+		return false
+	}
+}
+
+func (moebooruProvider) Probe(site *Site) bool {
+	probeURL := site.URL
+	probeURL.Path = "/post.json"
+	probeURL.RawQuery = "limit=1"
+
+	var posts []MoebooruPost
+	return probeJSON(site.Client, probeURL, nil, &posts)
+}
+
+func (moebooruProvider) GetPosts(site *Site, cursor Cursor, tags string) ([]Post, error) {
+	posts, err := GetPostsMoebooru(site.URL, cursor, tags, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return asPosts(posts), nil
+}
+
+func (moebooruProvider) OrderedByID(site *Site, tags string) bool {
+	return !hasCustomOrder(tags)
+}
+
+// Post pages look like /post/show/<id> or /post/show/<id>/<tags>
+var moebooruPostPath = regexp.MustCompile(`^/post/show/(\d+)`)
+
+func (moebooruProvider) ParsePostURL(postURL url.URL) (int64, bool) {
+	return parsePostPath(postURL, moebooruPostPath)
+}
+
+// Moebooru searches for 6 tags at once, one is left for the id:<N tag of page cursors
+func (moebooruProvider) TagLimit(site *Site) int {
+	return 5
+}
+
+func (moebooruProvider) OrSyntax(site *Site) OrSyntax {
+	return OrTilde
+}
+
+func (moebooruProvider) WithDates(site *Site, tags string, since time.Time, until time.Time) string {
+	return strings.TrimSpace(tags + " " + dateMetatag(since, until))
+}
+
+// Favorites are votes of 3, ordered by the time of voting
+func (moebooruProvider) FavoritesQuery(site *Site, user string) (string, bool) {
+	return "vote:3:" + user + " order:vote", true
+}
+
+type MoebooruPost struct {
+	chosenVariant
+	MediaHash       string
+	Host            string
+	PageURL         string
+	TagTypes        map[string]string
+	ChildIDs        []int64
+	ID              int64  `json:"id"`
+	PostTags        string `json:"tags"`
+	CreatedAt       int64  `json:"created_at"`
+	UpdatedAt       int64  `json:"updated_at"`
+	CreatorID       *int64 `json:"creator_id"`
+	ApproverID      *int64 `json:"approver_id"`
+	Author          string `json:"author"`
+	Change          int64  `json:"change"`
+	Source          string `json:"source"`
+	Score           int    `json:"score"`
+	MD5             string `json:"md5"`
+	FileSize        uint64 `json:"file_size"`
+	FileExt         string `json:"file_ext"`
+	FileURL         string `json:"file_url"`
+	IsShownInIndex  bool   `json:"is_shown_in_index"`
+	PreviewURL      string `json:"preview_url"`
+	PreviewWidth    int    `json:"preview_width"`
+	PreviewHeight   int    `json:"preview_height"`
+	SampleURL       string `json:"sample_url"`
+	SampleWidth     int    `json:"sample_width"`
+	SampleHeight    int    `json:"sample_height"`
+	SampleFileSize  uint64 `json:"sample_file_size"`
+	JpegURL         string `json:"jpeg_url"`
+	JpegWidth       int    `json:"jpeg_width"`
+	JpegHeight      int    `json:"jpeg_height"`
+	JpegFileSize    uint64 `json:"jpeg_file_size"`
+	Rating          string `json:"rating"`
+	IsRatingLocked  bool   `json:"is_rating_locked"`
+	HasChildren     bool   `json:"has_children"`
+	ParentID        *int64 `json:"parent_id"`
+	Status          string `json:"status"`
+	IsPending       bool   `json:"is_pending"`
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
+	IsHeld          bool   `json:"is_held"`
+	IsNoteLocked    bool   `json:"is_note_locked"`
+	LastNotedAt     int64  `json:"last_noted_at"`
+	LastCommentedAt int64  `json:"last_commented_at"`
+}
+
+// Response of /post.json when called with api_version=2 and include_tags=1
+type MoebooruJSONData struct {
+	Posts []MoebooruPost `json:"posts"`
+	// Tag name -> tag type ("general", "artist", "copyright", "character", "circle", "faults")
+	Tags map[string]string `json:"tags"`
+}
+
+// Decodes a page of posts. Boorus ignoring api_version give a bare array of posts without tag types
+func decodeMoebooruPosts(data []byte) (MoebooruJSONData, error) {
+	var galleryData MoebooruJSONData
+	if trimmed := bytes.TrimSpace(data); len(trimmed) != 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &galleryData.Posts)
+		return galleryData, err
+	}
+
+	err := json.Unmarshal(data, &galleryData)
+	return galleryData, err
+}
+
+// Retrieves a page of posts. Login and password hash (as API key), if given, are sent as query parameters
+func GetPostsMoebooru(moebooruURL url.URL, cursor Cursor, tags string, credentials Credentials, client *http.Client) ([]MoebooruPost, error) {
+	query := moebooruURL.Query()
+	if credentials.Login != "" && credentials.APIKey != "" {
+		query.Set("login", credentials.Login)
+		query.Set("password_hash", credentials.APIKey)
+	}
+	if cursor.BeforeID != 0 {
+		// Continue with an ID range instead of a page number
+		tags = strings.TrimSpace(fmt.Sprintf("%s id:<%d", tags, cursor.BeforeID))
+		query.Set("page", "1")
+	} else {
+		query.Set("page", fmt.Sprintf("%d", cursor.pageNumber()))
+	}
+	// Ask for tag types alongside posts, which needs the second version of the API
+	query.Set("api_version", "2")
+	query.Set("include_tags", "1")
+
+	if tags != "" {
+		query.Set("tags", tags)
+	}
+	moebooruURL.RawQuery = query.Encode()
+	moebooruURL.Path = "/post.json"
+
+	data, err := proxy.GetContents(client, moebooruURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	galleryData, err := decodeMoebooruPosts(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range galleryData.Posts {
+		galleryData.Posts[i].Host = moebooruURL.Hostname()
+		galleryData.Posts[i].PageURL = pageURL(moebooruURL, fmt.Sprintf("/post/show/%d", galleryData.Posts[i].ID), "")
+		galleryData.Posts[i].TagTypes = galleryData.Tags
+		galleryData.Posts[i].FileURL = resolveURL(moebooruURL, galleryData.Posts[i].FileURL)
+		galleryData.Posts[i].SampleURL = resolveURL(moebooruURL, galleryData.Posts[i].SampleURL)
+		galleryData.Posts[i].PreviewURL = resolveURL(moebooruURL, galleryData.Posts[i].PreviewURL)
+		galleryData.Posts[i].JpegURL = resolveURL(moebooruURL, galleryData.Posts[i].JpegURL)
+	}
+
+	return galleryData.Posts, nil
+}
+
+func (post *MoebooruPost) PostID() int64 {
+	return post.ID
+}
+
+// Returns post tags of the given types. Tags of unknown type are considered general
+func (post *MoebooruPost) tagsOfType(types ...string) []string {
+	var result []string
+	for _, tag := range strings.Fields(post.PostTags) {
+		tagType, ok := post.TagTypes[tag]
+		if !ok {
+			tagType = "general"
+		}
+
+		for _, wanted := range types {
+			if tagType == wanted {
+				result = append(result, tag)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+func (post *MoebooruPost) ParentPostID() int64 {
+	if post.ParentID == nil {
+		return 0
+	}
+
+	return *post.ParentID
+}
+
+func (post *MoebooruPost) HasChildPosts() bool {
+	return post.HasChildren
+}
+
+func (post *MoebooruPost) SetChildPostIDs(ids []int64) {
+	post.ChildIDs = ids
+}
+
+func (post *MoebooruPost) Tags() []string {
+	return post.tagsOfType("general")
+}
+
+func (post *MoebooruPost) Meta() []string {
+	return post.tagsOfType("faults")
+}
+
+func (post *MoebooruPost) Copyright() []string {
+	return post.tagsOfType("copyright")
+}
+
+func (post *MoebooruPost) Artists() []string {
+	return post.tagsOfType("artist", "circle")
+}
+
+func (post *MoebooruPost) Characters() []string {
+	return post.tagsOfType("character")
+}
+
+func (post *MoebooruPost) FileExtension() string {
+	if post.FileExt != "" {
+		return post.FileExt
+	}
+
+	return strings.TrimPrefix(filepath.Ext(post.FileURL), ".")
+}
+
+func (post *MoebooruPost) MediaURL() string {
+	if post.variant != nil {
+		return post.variant.URL
+	}
+
+	return post.originalURL()
+}
+
+func (post *MoebooruPost) originalURL() string {
+	if post.FileURL == "" {
+		// Fallback to JPEG version
+		if post.JpegURL == "" {
+			return post.SampleURL
+		}
+
+		return post.JpegURL
+	}
+
+	return post.FileURL
+}
+
+func (post *MoebooruPost) SaveMedia(directory string, client *http.Client) error {
+	mediaHash, size, err := saveMedia(post.MediaURL(), directory, client)
+	if err != nil {
+		return err
+	}
+	post.MediaHash = mediaHash
+	post.variantSize = size
+
+	return nil
+}
+
+func (post *MoebooruPost) Variants() []Variant {
+	return []Variant{
+		{
+			Type:   VariantPreview,
+			URL:    post.PreviewURL,
+			Width:  post.PreviewWidth,
+			Height: post.PreviewHeight,
+		},
+		{
+			Type:   VariantSample,
+			URL:    post.SampleURL,
+			Width:  post.SampleWidth,
+			Height: post.SampleHeight,
+			Size:   post.SampleFileSize,
+		},
+		{
+			// Full size JPEG version of PNG originals
+			Type:   "jpeg",
+			URL:    post.JpegURL,
+			Width:  post.JpegWidth,
+			Height: post.JpegHeight,
+			Size:   post.JpegFileSize,
+		},
+		{
+			Type:    VariantOriginal,
+			URL:     post.originalURL(),
+			Width:   post.Width,
+			Height:  post.Height,
+			FileExt: post.FileExt,
+			Size:    post.FileSize,
+		},
+	}
+}
+
+func (post *MoebooruPost) SaveMetadata(directory string) error {
+	return saveMetadata(post.Metadata(), directory)
+}
+
+func (post *MoebooruPost) IsImage() bool {
+	return isImageExtension(post.FileExtension())
+}
+
+func (post *MoebooruPost) IsVideo() bool {
+	return isVideoExtension(post.FileExtension())
+}
+
+func (post *MoebooruPost) Size() uint64 {
+	if post.variant != nil {
+		return post.variantSize
+	}
+
+	return post.FileSize
+}
+
+func (post *MoebooruPost) PostRating() Rating {
+	return parseRating(post.Rating, safeRatingLetters)
+}
+
+func (post *MoebooruPost) CreatedTime() time.Time {
+	if post.CreatedAt == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(post.CreatedAt, 0).UTC()
+}
+
+func (post *MoebooruPost) Metadata() *Metadata {
+	return &Metadata{
+		ID:         post.ID,
+		PostURL:    post.PageURL,
+		Tags:       post.Tags(),
+		Copyright:  post.Copyright(),
+		Characters: post.Characters(),
+		Artists:    post.Artists(),
+		Meta:       post.Meta(),
+		Rating:     post.PostRating(),
+		Score:      post.Score,
+		Width:      post.Width,
+		Height:     post.Height,
+		MD5:        post.MD5,
+		Source:     post.Source,
+		CreatedAt:  formatTime(post.CreatedTime()),
+		Hash:       post.MediaHash,
+		FromHost:   post.Host,
+		URL:        post.MediaURL(),
+		Size:       post.Size(),
+		Relations:  relationsOf(post.ParentPostID(), post.HasChildPosts(), post.ChildIDs),
+		Variant:    post.variantType(),
+	}
+}
+
+// Tag types of moebooru
+var moebooruTagCategories = map[int]string{
+	0: "general",
+	1: "artist",
+	3: "copyright",
+	4: "character",
+	5: "circle",
+	6: "faults",
+}
+
+type MoebooruTag struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Count     int    `json:"count"`
+	Type      int    `json:"type"`
+	Ambiguous bool   `json:"ambiguous"`
+}
+
+func (moebooruProvider) SearchTags(site *Site, prefix string, limit int) ([]TagInfo, error) {
+	tags, err := SearchTagsMoebooru(site.URL, prefix, limit, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return moebooruTagInfos(tags), nil
+}
+
+func (moebooruProvider) LookupTag(site *Site, name string) (*TagInfo, error) {
+	// Without wildcards names are matched exactly
+	tags, err := getMoebooruTags(site.URL, name, DefaultTagSearchLimit, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return findTagInfo(moebooruTagInfos(tags), name), nil
+}
+
+func moebooruTagInfos(tags []MoebooruTag) []TagInfo {
+	infos := make([]TagInfo, len(tags))
+	for i, tag := range tags {
+		infos[i] = TagInfo{
+			Name:      tag.Name,
+			Category:  categoryName(moebooruTagCategories, tag.Type),
+			PostCount: tag.Count,
+		}
+	}
+
+	return infos
+}
+
+// Retrieves at most limit tags starting with prefix, most used first
+func SearchTagsMoebooru(moebooruURL url.URL, prefix string, limit int, credentials Credentials, client *http.Client) ([]MoebooruTag, error) {
+	return getMoebooruTags(moebooruURL, prefix+"*", limit, credentials, client)
+}
+
+// Retrieves at most limit tags matching a name pattern (* is a wildcard), most used first
+func getMoebooruTags(moebooruURL url.URL, pattern string, limit int, credentials Credentials, client *http.Client) ([]MoebooruTag, error) {
+	query := url.Values{}
+	if credentials.Login != "" && credentials.APIKey != "" {
+		query.Set("login", credentials.Login)
+		query.Set("password_hash", credentials.APIKey)
+	}
+	query.Set("name", pattern)
+	query.Set("order", "count")
+	query.Set("limit", fmt.Sprintf("%d", limit))
+	moebooruURL.RawQuery = query.Encode()
+	moebooruURL.Path = "/tag.json"
+
+	data, err := proxy.GetContents(client, moebooruURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []MoebooruTag
+	err = json.Unmarshal(data, &tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,78 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func TestGetPostsMoebooruBareArray(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/moebooru_posts.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var apiVersion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiVersion = r.URL.Query().Get("api_version")
+		w.Write(fixture)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	posts, err := GetPostsMoebooru(*serverURL, PageCursor(1), "touhou", Credentials{}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if apiVersion != "2" {
+		t.Errorf("api_version is %q, want 2", apiVersion)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("got %d posts, want 2", len(posts))
+	}
+	if posts[0].ID != 1183946 || posts[0].SampleFileSize != 318405 || posts[1].ParentPostID() != 1183944 {
+		t.Errorf("posts decoded wrong: %+v", posts)
+	}
+	if posts[0].PageURL != fmt.Sprintf("%s/post/show/1183946", server.URL) {
+		t.Errorf("page URL is %s", posts[0].PageURL)
+	}
+}
+
+func TestGetPostsMoebooruWithTags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"posts":[{"id":5,"tags":"hakurei_reimu touhou","file_ext":"png"}],"tags":{"hakurei_reimu":"character","touhou":"copyright"}}`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	posts, err := GetPostsMoebooru(*serverURL, PageCursor(1), "", Credentials{}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posts) != 1 || posts[0].TagTypes["touhou"] != "copyright" {
+		t.Errorf("posts decoded wrong: %+v", posts)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// A box with text (usually a translation) placed over an image
+type Note struct {
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Body   string `json:"body"`
+}
+
+// Notes of a single post
+type PostNotes struct {
+	PostID int64 `json:"post_id"`
+	// Dimensions of the original image note boxes are placed on
+	ImageWidth  int    `json:"image_width"`
+	ImageHeight int    `json:"image_height"`
+	Notes       []Note `json:"notes"`
+}
+
+// Implemented by providers of boorus with post notes
+type NotesProvider interface {
+	// Retrieves notes of post waiting for limiter before requests, nil if it has none
+	GetNotes(site *Site, post Post, limiter *rate.Limiter) (*PostNotes, error)
+}
+
+// Retrieves notes of post, nil if it has none or the booru has no notes.
+// Limiter is waited for only if notes are requested
+func (site *Site) GetNotes(post Post, limiter *rate.Limiter) (*PostNotes, error) {
+	provider, ok := site.Provider.(NotesProvider)
+	if !ok {
+		return nil, nil
+	}
+
+	return provider.GetNotes(site, post, limiter)
+}
+
+// Writes notes as a <hash>_notes.json file in directory
+func (notes *PostNotes) Save(directory string, mediaHash string) error {
+	contents, err := json.Marshal(notes)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(
+		filepath.Join(directory, fmt.Sprintf("%s_notes.json", mediaHash)),
+		contents,
+		0644,
+	)
+}
+
+var notesPageTemplate = template.Must(template.New("notes").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Post {{.PostID}}</title>
+<style>
+body { margin: 0; background: #222; }
+.page { position: relative; display: inline-block; }
+.page img { display: block; max-width: 100%; }
+.note { position: absolute; box-sizing: border-box; border: 1px solid #000; background: rgba(255, 255, 238, 0.4); }
+.note .body { display: none; position: absolute; top: 100%; left: 0; z-index: 1; min-width: 12em; max-width: 30em;
+	padding: 0.3em; border: 1px solid #000; background: #ffe; color: #000; font-family: sans-serif; white-space: pre-wrap; }
+.note:hover { z-index: 2; }
+.note:hover .body { display: block; }
+</style>
+</head>
+<body>
+<div class="page">
+<img src="{{.Image}}" alt="Post {{.PostID}}">
+{{range .Boxes}}<div class="note" style="left: {{.Left}}%; top: {{.Top}}%; width: {{.Width}}%; height: {{.Height}}%;"><div class="body">{{.Text}}</div></div>
+{{end}}</div>
+</body>
+</html>
+`))
+
+var (
+	noteLineBreaks = regexp.MustCompile(`(?i)<br\s*/?>`)
+	noteMarkup     = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Writes a <hash>_notes.html page showing notes over the saved media file,
+// so it stays readable offline. Notes are shown as plain text on hover
+func (notes *PostNotes) SaveHTML(directory string, mediaHash string, mediaFileName string) error {
+	type box struct {
+		Left, Top, Width, Height string
+		Text                     string
+	}
+
+	// Positions are relative so the page works with any image size
+	percent := func(value int, total int) string {
+		if total == 0 {
+			return "0"
+		}
+		return fmt.Sprintf("%.4f", float64(value)*100.0/float64(total))
+	}
+
+	var boxes []box
+	for _, note := range notes.Notes {
+		text := noteLineBreaks.ReplaceAllString(note.Body, "\n")
+		text = html.UnescapeString(noteMarkup.ReplaceAllString(text, ""))
+
+		boxes = append(boxes, box{
+			Left:   percent(note.X, notes.ImageWidth),
+			Top:    percent(note.Y, notes.ImageHeight),
+			Width:  percent(note.Width, notes.ImageWidth),
+			Height: percent(note.Height, notes.ImageHeight),
+			Text:   strings.TrimSpace(text),
+		})
+	}
+
+	file, err := os.Create(filepath.Join(directory, fmt.Sprintf("%s_notes.html", mediaHash)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return notesPageTemplate.Execute(file, struct {
+		PostID int64
+		Image  string
+		Boxes  []box
+	}{
+		PostID: notes.PostID,
+		Image:  mediaFileName,
+		Boxes:  boxes,
+	})
+}
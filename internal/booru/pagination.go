@@ -0,0 +1,96 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Points at a page of search results
+type Cursor struct {
+	// 1-based page number, used by providers when BeforeID is 0
+	Page uint
+	// When non-zero, points at the page of posts with IDs lower than this one
+	BeforeID int64
+}
+
+// Returns cursor pointing at the given page number
+func PageCursor(page uint) Cursor {
+	if page == 0 {
+		page = 1
+	}
+
+	return Cursor{Page: page}
+}
+
+func (cursor Cursor) String() string {
+	if cursor.BeforeID != 0 {
+		return fmt.Sprintf("page %d (before post %d)", cursor.Page, cursor.BeforeID)
+	}
+
+	return fmt.Sprintf("page %d", cursor.Page)
+}
+
+// Returns the number of the page, treating 0 as the first one
+func (cursor Cursor) pageNumber() uint {
+	if cursor.Page == 0 {
+		return 1
+	}
+
+	return cursor.Page
+}
+
+// Metatags which change the order of search results
+var orderMetatags = []string{"order:", "sort:", "ordfav:", "ordpool:", "ordfavgroup:"}
+
+// Reports whether tags ask for results in any order other than the default descending ID
+func hasCustomOrder(tags string) bool {
+	for _, tag := range strings.Fields(strings.ToLower(tags)) {
+		for _, metatag := range orderMetatags {
+			if strings.HasPrefix(tag, metatag) && tag != metatag+"id_desc" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Reports whether results of searching for tags come in the default descending ID order
+func (site *Site) OrderedByID(tags string) bool {
+	return site.Provider.OrderedByID(site, tags)
+}
+
+// Returns cursor of the page following posts. Results ordered by descending ID are continued
+// from the lowest ID seen: unlike page numbers this is stable while new posts are uploaded and
+// is not subject to page number limits. Other results are continued by page number
+func (site *Site) NextCursor(current Cursor, posts []Post, tags string) Cursor {
+	next := Cursor{Page: current.pageNumber() + 1}
+
+	if site.Provider.OrderedByID(site, tags) {
+		for _, post := range posts {
+			if post.PostID() > 0 && (next.BeforeID == 0 || post.PostID() < next.BeforeID) {
+				next.BeforeID = post.PostID()
+			}
+		}
+	}
+
+	return next
+}
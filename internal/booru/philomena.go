@@ -0,0 +1,448 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register(philomenaProvider{})
+}
+
+type philomenaProvider struct{}
+
+func (philomenaProvider) Name() string {
+	return "philomena"
+}
+
+func (philomenaProvider) Claims(booruURL url.URL) bool {
+	switch booruURL.Hostname() {
+	case "derpibooru.org", "furbooru.org", "ponybooru.org", "manebooru.art", "tantabus.ai":
+		return true
+	default:
+		return false
+	}
+}
+
+func (philomenaProvider) Probe(site *Site) bool {
+	probeURL := site.URL
+	probeURL.Path = "/api/v1/json/search/images"
+	probeURL.RawQuery = "q=*&per_page=1"
+
+	var galleryData struct {
+		Images []json.RawMessage `json:"images"`
+	}
+	return probeJSON(site.Client, probeURL, nil, &galleryData) && galleryData.Images != nil
+}
+
+func (philomenaProvider) GetPosts(site *Site, cursor Cursor, tags string) ([]Post, error) {
+	posts, err := GetPostsPhilomena(site.URL, cursor, tags, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return asPosts(posts), nil
+}
+
+// Image pages look like /images/<id> or just /<id>
+var philomenaPostPath = regexp.MustCompile(`^(?:/images)?/(\d+)/?$`)
+
+func (philomenaProvider) ParsePostURL(postURL url.URL) (int64, bool) {
+	return parsePostPath(postURL, philomenaPostPath)
+}
+
+// Philomena can not order by favoriting time
+func (philomenaProvider) FavoritesQuery(site *Site, user string) (string, bool) {
+	return "faved_by:" + user, false
+}
+
+// Upload time bounds are added to the comma separated search as created_at fields
+func (philomenaProvider) WithDates(site *Site, tags string, since time.Time, until time.Time) string {
+	var terms []string
+	if strings.TrimSpace(tags) != "" {
+		terms = append(terms, fmt.Sprintf("(%s)", tags))
+	}
+	if !since.IsZero() {
+		terms = append(terms, "created_at.gte:"+since.UTC().Format(time.RFC3339))
+	}
+	if !until.IsZero() {
+		terms = append(terms, "created_at.lt:"+until.UTC().Format(time.RFC3339))
+	}
+
+	return strings.Join(terms, ", ")
+}
+
+// Philomena sorts by sf and sd parameters of the URL, defaulting to descending ID
+func (philomenaProvider) OrderedByID(site *Site, tags string) bool {
+	query := site.URL.Query()
+	sortField := query.Get("sf")
+	sortDirection := query.Get("sd")
+
+	return (sortField == "" || sortField == "id") && (sortDirection == "" || sortDirection == "desc")
+}
+
+type PhilomenaPost struct {
+	chosenVariant
+	MediaHash       string
+	Host            string
+	PageURL         string
+	ID              int64             `json:"id"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	FirstSeenAt     time.Time         `json:"first_seen_at"`
+	Width           int               `json:"width"`
+	Height          int               `json:"height"`
+	AspectRatio     float64           `json:"aspect_ratio"`
+	MimeType        string            `json:"mime_type"`
+	Format          string            `json:"format"`
+	FileSize        uint64            `json:"size"`
+	SHA512Hash      string            `json:"sha512_hash"`
+	OrigSHA512Hash  string            `json:"orig_sha512_hash"`
+	ViewURL         string            `json:"view_url"`
+	Representations map[string]string `json:"representations"`
+	PostTags        []string          `json:"tags"`
+	TagIDs          []int64           `json:"tag_ids"`
+	Score           int               `json:"score"`
+	Upvotes         int               `json:"upvotes"`
+	Downvotes       int               `json:"downvotes"`
+	Faves           int               `json:"faves"`
+	WilsonScore     float64           `json:"wilson_score"`
+	SourceURL       string            `json:"source_url"`
+	SourceURLs      []string          `json:"source_urls"`
+	Uploader        *string           `json:"uploader"`
+	UploaderID      *int64            `json:"uploader_id"`
+	Description     string            `json:"description"`
+	Duration        float64           `json:"duration"`
+	Animated        bool              `json:"animated"`
+	Processed       bool              `json:"processed"`
+	HiddenFromUsers bool              `json:"hidden_from_users"`
+	DuplicateOf     *int64            `json:"duplicate_of"`
+	CommentCount    int               `json:"comment_count"`
+	Spoilered       bool              `json:"spoilered"`
+}
+
+type PhilomenaJSONData struct {
+	Images []PhilomenaPost `json:"images"`
+	Total  int             `json:"total"`
+}
+
+// Philomena namespaces artist tags as "artist:name"
+const philomenaArtistNamespace string = "artist:"
+
+// Queries Philomena's search API. Tags are passed as is in the q parameter, so Philomena
+// search syntax applies (comma separated tags). per_page, sf, sd and key parameters can be
+// given in philomenaURL's query, API key from credentials takes precedence over the latter
+func GetPostsPhilomena(philomenaURL url.URL, cursor Cursor, tags string, credentials Credentials, client *http.Client) ([]PhilomenaPost, error) {
+	query := philomenaURL.Query()
+	if credentials.APIKey != "" {
+		query.Set("key", credentials.APIKey)
+	}
+	if cursor.BeforeID != 0 {
+		// Continue with an ID range instead of a page number
+		if tags != "" {
+			tags = fmt.Sprintf("(%s), id.lt:%d", tags, cursor.BeforeID)
+		} else {
+			tags = fmt.Sprintf("id.lt:%d", cursor.BeforeID)
+		}
+		query.Set("page", "1")
+	} else {
+		query.Set("page", fmt.Sprintf("%d", cursor.pageNumber()))
+	}
+
+	if query.Get("per_page") == "" {
+		// Maximum allowed by the API
+		query.Set("per_page", "50")
+	}
+
+	if tags != "" {
+		query.Set("q", tags)
+	} else {
+		// Search query is mandatory, match everything
+		query.Set("q", "*")
+	}
+	philomenaURL.RawQuery = query.Encode()
+	philomenaURL.Path = "/api/v1/json/search/images"
+
+	data, err := proxy.GetContents(client, philomenaURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var galleryData PhilomenaJSONData
+	err = json.Unmarshal(data, &galleryData)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range galleryData.Images {
+		galleryData.Images[i].Host = philomenaURL.Hostname()
+		galleryData.Images[i].PageURL = pageURL(philomenaURL, fmt.Sprintf("/images/%d", galleryData.Images[i].ID), "")
+		galleryData.Images[i].ViewURL = resolveURL(philomenaURL, galleryData.Images[i].ViewURL)
+		for name, representation := range galleryData.Images[i].Representations {
+			galleryData.Images[i].Representations[name] = resolveURL(philomenaURL, representation)
+		}
+	}
+
+	return galleryData.Images, nil
+}
+
+func (post *PhilomenaPost) PostID() int64 {
+	return post.ID
+}
+
+func (post *PhilomenaPost) Tags() []string {
+	var tags []string
+	for _, tag := range post.PostTags {
+		if !strings.HasPrefix(tag, philomenaArtistNamespace) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+func (post *PhilomenaPost) Copyright() []string {
+	return nil
+}
+
+func (post *PhilomenaPost) Artists() []string {
+	var artists []string
+	for _, tag := range post.PostTags {
+		if strings.HasPrefix(tag, philomenaArtistNamespace) {
+			artists = append(artists, strings.TrimPrefix(tag, philomenaArtistNamespace))
+		}
+	}
+
+	return artists
+}
+
+func (post *PhilomenaPost) Characters() []string {
+	return nil
+}
+
+func (post *PhilomenaPost) FileExtension() string {
+	return post.Format
+}
+
+func (post *PhilomenaPost) MediaURL() string {
+	if post.variant != nil {
+		return post.variant.URL
+	}
+
+	return post.originalURL()
+}
+
+func (post *PhilomenaPost) originalURL() string {
+	if fullURL, ok := post.Representations["full"]; ok && fullURL != "" {
+		return fullURL
+	}
+
+	// Fallback to view URL
+	return post.ViewURL
+}
+
+func (post *PhilomenaPost) SaveMedia(directory string, client *http.Client) error {
+	mediaHash, size, err := saveMedia(post.MediaURL(), directory, client)
+	if err != nil {
+		return err
+	}
+	post.MediaHash = mediaHash
+	post.variantSize = size
+
+	return nil
+}
+
+// Bounding boxes of philomena representations, images are never upscaled
+var philomenaRepresentationBoxes = map[string][2]int{
+	"thumb_tiny":  {50, 50},
+	"thumb_small": {150, 150},
+	"thumb":       {250, 250},
+	"small":       {320, 240},
+	"medium":      {800, 600},
+	"large":       {1280, 1024},
+	"tall":        {1024, 4096},
+}
+
+func (post *PhilomenaPost) Variants() []Variant {
+	variants := []Variant{
+		{
+			Type:    VariantOriginal,
+			URL:     post.originalURL(),
+			Width:   post.Width,
+			Height:  post.Height,
+			FileExt: post.FileExtension(),
+		},
+	}
+
+	for name, representationURL := range post.Representations {
+		box, ok := philomenaRepresentationBoxes[name]
+		if !ok || post.Width == 0 || post.Height == 0 {
+			continue
+		}
+
+		scale := min(1.0, float64(box[0])/float64(post.Width), float64(box[1])/float64(post.Height))
+		variants = append(variants, Variant{
+			Type:   name,
+			URL:    representationURL,
+			Width:  int(float64(post.Width) * scale),
+			Height: int(float64(post.Height) * scale),
+		})
+	}
+
+	return variants
+}
+
+func (post *PhilomenaPost) SaveMetadata(directory string) error {
+	return saveMetadata(post.Metadata(), directory)
+}
+
+func (post *PhilomenaPost) IsImage() bool {
+	return strings.HasPrefix(post.MimeType, "image/") || isImageExtension(post.FileExtension())
+}
+
+func (post *PhilomenaPost) IsVideo() bool {
+	return strings.HasPrefix(post.MimeType, "video/") || isVideoExtension(post.FileExtension())
+}
+
+func (post *PhilomenaPost) Size() uint64 {
+	if post.variant != nil {
+		return post.variantSize
+	}
+
+	return post.FileSize
+}
+
+// Philomena rates posts with tags (safe, suggestive, questionable, explicit)
+func (post *PhilomenaPost) PostRating() Rating {
+	for _, tag := range post.PostTags {
+		if rating := parseRating(tag, nil); rating != RatingUnknown {
+			return rating
+		}
+	}
+
+	return RatingUnknown
+}
+
+func (post *PhilomenaPost) CreatedTime() time.Time {
+	return post.CreatedAt
+}
+
+func (post *PhilomenaPost) Metadata() *Metadata {
+	source := post.SourceURL
+	if source == "" && len(post.SourceURLs) > 0 {
+		source = post.SourceURLs[0]
+	}
+
+	return &Metadata{
+		ID:         post.ID,
+		PostURL:    post.PageURL,
+		Tags:       post.Tags(),
+		Copyright:  post.Copyright(),
+		Characters: post.Characters(),
+		Artists:    post.Artists(),
+		Rating:     post.PostRating(),
+		Score:      post.Score,
+		FavCount:   reportedCount(post.Faves),
+		Width:      post.Width,
+		Height:     post.Height,
+		Duration:   post.Duration,
+		Source:     source,
+		CreatedAt:  formatTime(post.CreatedTime()),
+		Hash:       post.MediaHash,
+		FromHost:   post.Host,
+		URL:        post.MediaURL(),
+		Size:       post.Size(),
+		Variant:    post.variantType(),
+	}
+}
+
+type PhilomenaTag struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	Category string `json:"category"`
+	Images   int    `json:"images"`
+}
+
+type PhilomenaTagJSONData struct {
+	Tags  []PhilomenaTag `json:"tags"`
+	Total int            `json:"total"`
+}
+
+func (philomenaProvider) SearchTags(site *Site, prefix string, limit int) ([]TagInfo, error) {
+	tags, err := SearchTagsPhilomena(site.URL, prefix, limit, site.Credentials, site.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]TagInfo, len(tags))
+	for i, tag := range tags {
+		// Tags without a category are general ones
+		category := tag.Category
+		switch category {
+		case "":
+			category = "general"
+		case "origin":
+			category = "artist"
+		}
+
+		infos[i] = TagInfo{
+			Name:      tag.Name,
+			Category:  category,
+			PostCount: tag.Images,
+		}
+	}
+
+	return infos, nil
+}
+
+// Retrieves at most limit tags starting with prefix, most used first
+func SearchTagsPhilomena(philomenaURL url.URL, prefix string, limit int, credentials Credentials, client *http.Client) ([]PhilomenaTag, error) {
+	query := url.Values{}
+	if credentials.APIKey != "" {
+		query.Set("key", credentials.APIKey)
+	}
+	query.Set("q", prefix+"*")
+	query.Set("sf", "images")
+	query.Set("sd", "desc")
+	query.Set("per_page", fmt.Sprintf("%d", min(limit, 50)))
+	philomenaURL.RawQuery = query.Encode()
+	philomenaURL.Path = "/api/v1/json/search/tags"
+
+	data, err := proxy.GetContents(client, philomenaURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var tagData PhilomenaTagJSONData
+	err = json.Unmarshal(data, &tagData)
+	if err != nil {
+		return nil, err
+	}
+
+	return tagData.Tags, nil
+}
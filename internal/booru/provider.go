@@ -0,0 +1,253 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// Provider implements access to a single booru engine
+type Provider interface {
+	// Unique engine name, used to pick the provider explicitly
+	Name() string
+	// Reports whether booruURL is known to be served by this engine
+	Claims(booruURL url.URL) bool
+	// Queries well-known endpoints to check whether the site speaks this engine's API
+	Probe(site *Site) bool
+	// Retrieves a page of posts with given tags
+	GetPosts(site *Site, cursor Cursor, tags string) ([]Post, error)
+	// Reports whether posts found with tags come in descending ID order,
+	// so that paging can continue from the lowest ID seen
+	OrderedByID(site *Site, tags string) bool
+	// Extracts post ID from the URL of a post page on this engine
+	ParsePostURL(postURL url.URL) (int64, bool)
+	// Returns search query for posts favorited by user and whether
+	// its results come in the order of favoriting, newest first
+	FavoritesQuery(site *Site, user string) (string, bool)
+}
+
+var ErrBooruNotSupported error = errors.New("this booru is not supported")
+var ErrPostNotFound error = errors.New("post not found")
+var ErrUnknownEngine error = errors.New("unknown booru engine")
+
+// Registered providers in the order of registration
+var providers []Provider
+
+// Makes provider available for selection and auto-detection. Meant to be called from init
+func Register(provider Provider) {
+	for _, registered := range providers {
+		if registered.Name() == provider.Name() {
+			panic("booru provider " + provider.Name() + " is already registered")
+		}
+	}
+
+	providers = append(providers, provider)
+}
+
+// Returns names of all registered providers
+func ProviderNames() []string {
+	names := make([]string, len(providers))
+	for i, provider := range providers {
+		names[i] = provider.Name()
+	}
+
+	return names
+}
+
+// Returns a registered provider with the given name
+func GetProvider(name string) (Provider, error) {
+	for _, provider := range providers {
+		if provider.Name() == strings.ToLower(strings.TrimSpace(name)) {
+			return provider, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownEngine, name)
+}
+
+// Credentials to authenticate with a booru. Each provider uses the fields its API needs:
+// danbooru and e621 take login and API key, gelbooru takes API key and user ID,
+// moebooru takes login and password hash as API key, philomena takes only API key
+// and szurubooru takes login and login token as API key
+type Credentials struct {
+	Login  string
+	APIKey string
+	UserID string
+}
+
+// Returns HTTP basic authorization headers for login and API key, if set
+func (credentials Credentials) basicAuthHeaders() map[string]string {
+	if credentials.Login == "" || credentials.APIKey == "" {
+		return nil
+	}
+
+	return map[string]string{
+		"Authorization": "Basic " + base64.StdEncoding.EncodeToString(
+			[]byte(credentials.Login+":"+credentials.APIKey),
+		),
+	}
+}
+
+// A booru resolved to the provider serving it
+type Site struct {
+	URL         url.URL
+	Client      *http.Client
+	Credentials Credentials
+	Provider    Provider
+	// Waited for before requests the booru makes on its own, such as tag type
+	// lookups of a page of posts. Nil if these are not rate limited
+	Limiter *rate.Limiter
+
+	tagLimitOnce sync.Once
+	tagLimit     int
+	tagListed    bool
+}
+
+// Waits for the limiter of the site, if there is one
+func (site *Site) wait() error {
+	if site.Limiter == nil {
+		return nil
+	}
+
+	return site.Limiter.Wait(context.Background())
+}
+
+// Resolves a provider for booruURL. An explicitly named engine is used as is, otherwise
+// the first provider claiming the URL is picked. If none does, well-known endpoints of each
+// provider are probed
+func NewSite(booruURL url.URL, engine string, credentials Credentials, client *http.Client) (*Site, error) {
+	site := &Site{
+		URL:         booruURL,
+		Client:      client,
+		Credentials: credentials,
+	}
+
+	if strings.TrimSpace(engine) != "" {
+		provider, err := GetProvider(engine)
+		if err != nil {
+			return nil, err
+		}
+		site.Provider = provider
+		return site, nil
+	}
+
+	for _, provider := range providers {
+		if provider.Claims(booruURL) {
+			site.Provider = provider
+			return site, nil
+		}
+	}
+
+	for _, provider := range providers {
+		if provider.Probe(site) {
+			site.Provider = provider
+			return site, nil
+		}
+	}
+
+	return nil, ErrBooruNotSupported
+}
+
+func (site *Site) GetPosts(cursor Cursor, tags string) ([]Post, error) {
+	return site.Provider.GetPosts(site, cursor, tags)
+}
+
+// Retrieves a single post by its ID
+func (site *Site) GetPost(id int64) (Post, error) {
+	// Every supported engine understands id:N searches
+	posts, err := site.GetPosts(PageCursor(1), fmt.Sprintf("id:%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		if post.PostID() == id {
+			return post, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %d", ErrPostNotFound, id)
+}
+
+// Extracts post ID from the URL of a post page on this site
+func (site *Site) ParsePostURL(postURL url.URL) (int64, bool) {
+	return site.Provider.ParsePostURL(postURL)
+}
+
+// Returns search query for posts favorited by user and whether
+// its results come in the order of favoriting, newest first
+func (site *Site) FavoritesQuery(user string) (string, bool) {
+	return site.Provider.FavoritesQuery(site, user)
+}
+
+// Converts a slice of concrete posts to a slice of Post
+func asPosts[T any, P interface {
+	*T
+	Post
+}](items []T) []Post {
+	posts := make([]Post, len(items))
+	for i := range items {
+		posts[i] = P(&items[i])
+	}
+
+	return posts
+}
+
+// Requests probeURL and reports whether its response decodes into target
+func probeJSON(client *http.Client, probeURL url.URL, headers map[string]string, target any) bool {
+	data, err := proxy.GetContentsWithHeaders(client, probeURL.String(), headers)
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(data, target) == nil
+}
+
+// Matches post ID in the path of a post page URL with pattern, which must capture the ID
+func parsePostPath(postURL url.URL, pattern *regexp.Regexp) (int64, bool) {
+	match := pattern.FindStringSubmatch(postURL.Path)
+	if match == nil {
+		return 0, false
+	}
+
+	id, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return id, true
+}
+
+// Reports whether host is domain itself or one of its subdomains
+func isHostOf(host string, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
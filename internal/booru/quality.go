@@ -0,0 +1,227 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Media of a post in one of the sizes a booru offers
+type Variant struct {
+	Type    string `json:"type"`
+	URL     string `json:"url"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	FileExt string `json:"file_ext"`
+	// In bytes as reported by the booru, 0 if unknown
+	Size uint64 `json:"file_size,omitempty"`
+}
+
+// Common variant types, boorus may have others
+const (
+	VariantOriginal string = "original"
+	VariantSample   string = "sample"
+	VariantPreview  string = "preview"
+)
+
+// Implemented by posts offering media in several sizes
+type VariantPost interface {
+	Post
+	// Returns media variants of the post, the original included
+	Variants() []Variant
+	// Makes the post save variant instead of the original media
+	UseVariant(variant Variant)
+}
+
+// Variant chosen in place of the original media, embedded into posts
+type chosenVariant struct {
+	variant *Variant
+	// Reported by the booru until the variant is saved, 0 if unknown
+	variantSize uint64
+}
+
+func (chosen *chosenVariant) UseVariant(variant Variant) {
+	chosen.variant = &variant
+	chosen.variantSize = variant.Size
+}
+
+// Returns type of the chosen variant, blank if it is the original
+func (chosen *chosenVariant) variantType() string {
+	if chosen.variant == nil {
+		return ""
+	}
+
+	return chosen.variant.Type
+}
+
+type qualityMode int
+
+const (
+	qualityOriginal qualityMode = iota
+	qualitySample
+	qualityBox
+	qualityMaxWidth
+	qualitySmallestAbove
+)
+
+// Decides which media variant of a post is saved
+type QualityPolicy struct {
+	mode   qualityMode
+	width  int
+	height int
+}
+
+// Parses a quality policy: "original", "sample", "N" (largest variant fitting into NxN),
+// "max-width=N" (largest variant at most N pixels wide) or "smallest-above=WxH"
+// (smallest variant at least W by H pixels). Blank means original
+func ParseQualityPolicy(policy string) (QualityPolicy, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+
+	parseSize := func(value string) (int, error) {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			return 0, fmt.Errorf("invalid size %q in quality policy", value)
+		}
+		return size, nil
+	}
+
+	switch {
+	case policy == "" || policy == "original":
+		return QualityPolicy{mode: qualityOriginal}, nil
+
+	case policy == "sample":
+		return QualityPolicy{mode: qualitySample}, nil
+
+	case strings.HasPrefix(policy, "max-width="):
+		width, err := parseSize(strings.TrimPrefix(policy, "max-width="))
+		if err != nil {
+			return QualityPolicy{}, err
+		}
+		return QualityPolicy{mode: qualityMaxWidth, width: width}, nil
+
+	case strings.HasPrefix(policy, "smallest-above="):
+		width, height, found := strings.Cut(strings.TrimPrefix(policy, "smallest-above="), "x")
+		if !found {
+			return QualityPolicy{}, fmt.Errorf("quality policy %q is not of smallest-above=WxH form", policy)
+		}
+		minWidth, err := parseSize(width)
+		if err != nil {
+			return QualityPolicy{}, err
+		}
+		minHeight, err := parseSize(height)
+		if err != nil {
+			return QualityPolicy{}, err
+		}
+		return QualityPolicy{mode: qualitySmallestAbove, width: minWidth, height: minHeight}, nil
+
+	default:
+		size, err := parseSize(policy)
+		if err != nil {
+			return QualityPolicy{}, fmt.Errorf("unknown quality policy %q", policy)
+		}
+		return QualityPolicy{mode: qualityBox, width: size, height: size}, nil
+	}
+}
+
+// Picks a variant according to the policy. Returns false if the original should be kept
+func (policy QualityPolicy) Choose(variants []Variant) (Variant, bool) {
+	if policy.mode == qualityOriginal {
+		return Variant{}, false
+	}
+
+	var candidates []Variant
+	for _, variant := range variants {
+		if variant.URL != "" && variant.Width > 0 && variant.Height > 0 {
+			candidates = append(candidates, variant)
+		}
+	}
+
+	area := func(variant Variant) int {
+		return variant.Width * variant.Height
+	}
+
+	// Returns the largest (or the smallest) of candidates that fit, ok is false if none do
+	pick := func(fits func(Variant) bool, largest bool) (chosen Variant, ok bool) {
+		for _, variant := range candidates {
+			if !fits(variant) {
+				continue
+			}
+			if !ok || (largest && area(variant) > area(chosen)) || (!largest && area(variant) < area(chosen)) {
+				chosen, ok = variant, true
+			}
+		}
+		return chosen, ok
+	}
+	anySize := func(Variant) bool { return true }
+
+	var (
+		chosen Variant
+		ok     bool
+	)
+	switch policy.mode {
+	case qualitySample:
+		for _, variant := range variants {
+			if variant.Type == VariantSample && variant.URL != "" {
+				chosen, ok = variant, true
+				break
+			}
+		}
+
+	case qualityBox, qualityMaxWidth:
+		chosen, ok = pick(func(variant Variant) bool {
+			return variant.Width <= policy.width && (policy.mode == qualityMaxWidth || variant.Height <= policy.height)
+		}, true)
+		if !ok {
+			// Nothing is small enough, so go as small as possible
+			chosen, ok = pick(anySize, false)
+		}
+
+	case qualitySmallestAbove:
+		// Otherwise nothing is large enough and the original is the best there is
+		chosen, ok = pick(func(variant Variant) bool {
+			return variant.Width >= policy.width && variant.Height >= policy.height
+		}, false)
+	}
+
+	if !ok || chosen.Type == VariantOriginal {
+		return Variant{}, false
+	}
+
+	return chosen, true
+}
+
+// Makes post save the variant chosen by the policy. Only still images are affected,
+// as variants of videos and animations are still previews. Returns whether a variant is used
+func (policy QualityPolicy) Apply(post Post) bool {
+	variantPost, ok := post.(VariantPost)
+	if !ok || !post.IsImage() || strings.EqualFold(filepath.Ext(post.MediaURL()), ".gif") {
+		return false
+	}
+
+	variant, ok := policy.Choose(variantPost.Variants())
+	if !ok {
+		return false
+	}
+
+	variantPost.UseVariant(variant)
+	return true
+}
@@ -0,0 +1,91 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Content rating of a post, named the same for every booru
+type Rating string
+
+const (
+	RatingUnknown      Rating = ""
+	RatingGeneral      Rating = "general"
+	RatingSensitive    Rating = "sensitive"
+	RatingQuestionable Rating = "questionable"
+	RatingExplicit     Rating = "explicit"
+)
+
+// Rating letters of danbooru
+var danbooruRatingLetters = map[string]Rating{
+	"g": RatingGeneral,
+	"s": RatingSensitive,
+	"q": RatingQuestionable,
+	"e": RatingExplicit,
+}
+
+// Rating letters of moebooru, e621 and gelbooru 0.2, where "s" stands for safe
+var safeRatingLetters = map[string]Rating{
+	"s": RatingGeneral,
+	"q": RatingQuestionable,
+	"e": RatingExplicit,
+}
+
+// Returns rating given by its name (as used by gelbooru, philomena or szurubooru)
+// or by its letter in letters table
+func parseRating(name string, letters map[string]Rating) Rating {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "general", "safe":
+		return RatingGeneral
+	case "sensitive", "suggestive":
+		return RatingSensitive
+	case "questionable", "sketchy":
+		return RatingQuestionable
+	case "explicit", "unsafe":
+		return RatingExplicit
+	}
+
+	return letters[name]
+}
+
+// Parses rating given by its name or by its danbooru letter (g, s, q, e)
+func ParseRating(name string) Rating {
+	return parseRating(name, danbooruRatingLetters)
+}
+
+// Parses a comma separated list of ratings given by their names or danbooru letters
+func ParseRatings(list string) ([]Rating, error) {
+	var ratings []Rating
+	for _, name := range strings.Split(list, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+
+		rating := ParseRating(name)
+		if rating == RatingUnknown {
+			return nil, fmt.Errorf("unknown rating %q (expected general, sensitive, questionable or explicit)", strings.TrimSpace(name))
+		}
+		ratings = append(ratings, rating)
+	}
+
+	return ratings, nil
+}
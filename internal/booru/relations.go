@@ -0,0 +1,155 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"golang.org/x/time/rate"
+)
+
+// Parent/child relations of a post
+type Relations struct {
+	ParentID    int64 `json:"parent_id,omitempty"`
+	HasChildren bool  `json:"has_children,omitempty"`
+	// Known only once the family of the post was looked up
+	ChildIDs []int64 `json:"child_ids,omitempty"`
+}
+
+// Implemented by posts of boorus with parent/child relations
+type RelatedPost interface {
+	Post
+	// Returns ID of the parent post, 0 if there is none
+	ParentPostID() int64
+	HasChildPosts() bool
+	// Remembers IDs of child posts found for the post
+	SetChildPostIDs(ids []int64)
+}
+
+// Returns relations to put into metadata, nil if post is not related to any other
+func relationsOf(parentID int64, hasChildren bool, childIDs []int64) *Relations {
+	if parentID == 0 && !hasChildren && len(childIDs) == 0 {
+		return nil
+	}
+
+	return &Relations{
+		ParentID:    parentID,
+		HasChildren: hasChildren || len(childIDs) != 0,
+		ChildIDs:    childIDs,
+	}
+}
+
+// Returns ID of the topmost post of the family post belongs to, 0 if it belongs to none
+func FamilyRootID(post Post) int64 {
+	related, ok := post.(RelatedPost)
+	if !ok {
+		return 0
+	}
+
+	if related.ParentPostID() != 0 {
+		return related.ParentPostID()
+	}
+
+	if related.HasChildPosts() {
+		return related.PostID()
+	}
+
+	return 0
+}
+
+// Retrieves all posts of the family post belongs to: the root post (its parent or itself)
+// and children of the root, waiting for limiter before each request. Child IDs of the
+// members and of post are filled in. Returns nil if post has no family
+func (site *Site) GetFamily(post Post, limiter *rate.Limiter) ([]Post, error) {
+	rootID := FamilyRootID(post)
+	if rootID == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf("parent:%d", rootID)
+	byID := make(map[int64]Post)
+	var family []Post
+
+	cursor := PageCursor(1)
+	for {
+		if err := limiter.Wait(context.Background()); err != nil {
+			return nil, err
+		}
+
+		posts, err := site.GetPosts(cursor, query)
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, member := range posts {
+			if _, ok := byID[member.PostID()]; ok {
+				continue
+			}
+			byID[member.PostID()] = member
+			family = append(family, member)
+			added++
+		}
+
+		if added == 0 {
+			break
+		}
+		cursor = site.NextCursor(cursor, posts, query)
+	}
+
+	// Some boorus only find children with parent:N
+	if _, ok := byID[rootID]; !ok {
+		if err := limiter.Wait(context.Background()); err != nil {
+			return nil, err
+		}
+
+		root, err := site.GetPost(rootID)
+		if err != nil && !errors.Is(err, ErrPostNotFound) {
+			return nil, err
+		}
+		if root != nil {
+			byID[rootID] = root
+			family = append([]Post{root}, family...)
+		}
+	}
+
+	if _, ok := byID[post.PostID()]; !ok {
+		family = append(family, post)
+	}
+
+	children := make(map[int64][]int64)
+	for _, member := range family {
+		if related, ok := member.(RelatedPost); ok && related.ParentPostID() != 0 {
+			children[related.ParentPostID()] = append(children[related.ParentPostID()], member.PostID())
+		}
+	}
+
+	for _, member := range append(family, post) {
+		if related, ok := member.(RelatedPost); ok {
+			childIDs := slices.Clone(children[member.PostID()])
+			slices.Sort(childIDs)
+			related.SetChildPostIDs(childIDs)
+		}
+	}
+
+	return family, nil
+}
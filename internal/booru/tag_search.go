@@ -0,0 +1,171 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package booru
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Amount of tags returned by a tag search when no limit is given
+const DefaultTagSearchLimit int = 20
+
+// A tag found by tag search
+type TagInfo struct {
+	Name string `json:"name"`
+	// general, artist, copyright, character, meta or a booru specific category
+	Category  string `json:"category"`
+	PostCount int    `json:"post_count"`
+}
+
+// Implemented by providers able to look up tags
+type TagSearchProvider interface {
+	// Returns at most limit tags starting with prefix, most used first
+	SearchTags(site *Site, prefix string, limit int) ([]TagInfo, error)
+}
+
+// Returns at most limit tags starting with prefix, most used first
+func (site *Site) SearchTags(prefix string, limit int) ([]TagInfo, error) {
+	provider, ok := site.Provider.(TagSearchProvider)
+	if !ok {
+		return nil, fmt.Errorf("tag search: %w", ErrNotSupported)
+	}
+
+	prefix = strings.TrimSpace(prefix)
+	if limit <= 0 {
+		limit = DefaultTagSearchLimit
+	}
+
+	return provider.SearchTags(site, prefix, limit)
+}
+
+// Returns name of category from a table of numeric categories, the number itself if unknown
+func categoryName(categories map[int]string, category int) string {
+	name, ok := categories[category]
+	if !ok {
+		return fmt.Sprintf("%d", category)
+	}
+
+	return name
+}
+
+// Implemented by providers searching with space separated lists of tags,
+// which may be negated with "-", contain "*" wildcards or be "name:value" metatags
+type TagListProvider interface {
+	// Returns max amount of tags in a search, 0 if there is no limit
+	TagLimit(site *Site) int
+}
+
+// Reports whether the booru searches with lists of tags and how many tags a search
+// may have at most (0 if there is no limit). The limit is found out once per site
+func (site *Site) TagLimit() (int, bool) {
+	site.tagLimitOnce.Do(func() {
+		provider, ok := site.Provider.(TagListProvider)
+		if ok {
+			site.tagLimit = provider.TagLimit(site)
+			site.tagListed = true
+		}
+	})
+
+	return site.tagLimit, site.tagListed
+}
+
+// How a booru searches for posts having any of several tags
+type OrSyntax int
+
+const (
+	// The booru can not search for posts having any of several tags
+	OrUnsupported OrSyntax = iota
+	// ~tag1 ~tag2, a single group of alternatives per search
+	OrTilde
+	// {tag1 ~ tag2}, any amount of groups per search
+	OrBraces
+)
+
+// Implemented by tag list providers able to search for posts having any of several tags
+type OrSearchProvider interface {
+	OrSyntax(site *Site) OrSyntax
+}
+
+// Returns how the booru searches for posts having any of several tags
+func (site *Site) OrSyntax() OrSyntax {
+	provider, ok := site.Provider.(OrSearchProvider)
+	if !ok {
+		return OrUnsupported
+	}
+
+	return provider.OrSyntax(site)
+}
+
+// Implemented by providers able to look up tags by their exact names
+type TagLookupProvider interface {
+	// Returns the tag named name, nil if there is none
+	LookupTag(site *Site, name string) (*TagInfo, error)
+}
+
+// Returns amount of posts with tag. Reports false if the amount is unknown,
+// as for tags the booru does not list (e.g. aliases)
+func (site *Site) TagPostCount(tag string) (int, bool, error) {
+	provider, ok := site.Provider.(TagLookupProvider)
+	if !ok {
+		return 0, false, fmt.Errorf("tag lookup: %w", ErrNotSupported)
+	}
+
+	info, err := provider.LookupTag(site, tag)
+	if err != nil {
+		return 0, false, err
+	}
+	if info == nil {
+		return 0, false, nil
+	}
+
+	return info.PostCount, true, nil
+}
+
+// Returns the first of infos named name, nil if there is none
+func findTagInfo(infos []TagInfo, name string) *TagInfo {
+	for i := range infos {
+		if strings.EqualFold(infos[i].Name, name) {
+			return &infos[i]
+		}
+	}
+
+	return nil
+}
+
+// Returns every tag of the post, whatever the category
+func AllTags(post Post) []string {
+	var tags []string
+	tags = append(tags, post.Tags()...)
+	tags = append(tags, post.Artists()...)
+	tags = append(tags, post.Characters()...)
+	tags = append(tags, post.Copyright()...)
+
+	if post, ok := post.(interface{ Meta() []string }); ok {
+		tags = append(tags, post.Meta()...)
+	}
+	if post, ok := post.(interface{ Species() []string }); ok {
+		tags = append(tags, post.Species()...)
+	}
+	if post, ok := post.(interface{ Lore() []string }); ok {
+		tags = append(tags, post.Lore()...)
+	}
+
+	return tags
+}
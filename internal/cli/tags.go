@@ -0,0 +1,50 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cli
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/booru"
+	"Unbewohnte/gobooru-downloader/internal/config"
+	"Unbewohnte/gobooru-downloader/internal/logger"
+	"encoding/json"
+	"os"
+)
+
+// Searches tags starting with the configured prefix and prints them to stdout as JSON
+func RunTags(cfg *config.TagsConfig) error {
+	site, err := booru.NewSite(*cfg.BooruURL, cfg.Engine, cfg.Credentials, cfg.HTTPClient)
+	if err != nil {
+		logger.Error("[Tags] Failed to set up %s: %s", cfg.BooruURL.Host, err)
+		return err
+	}
+
+	tags, err := site.SearchTags(cfg.Prefix, cfg.Limit)
+	if err != nil {
+		logger.Error("[Tags] Failed to search tags on %s: %s", cfg.BooruURL.Host, err)
+		return err
+	}
+
+	if tags == nil {
+		tags = []booru.TagInfo{}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(tags)
+}
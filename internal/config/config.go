@@ -19,14 +19,19 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"unicode"
 
+	"Unbewohnte/gobooru-downloader/internal/booru"
 	"Unbewohnte/gobooru-downloader/internal/logger"
 	"Unbewohnte/gobooru-downloader/internal/proxy"
 )
@@ -34,6 +39,8 @@ import (
 type Config struct {
 	Version         bool
 	BooruURL        *url.URL
+	Engine          string
+	Credentials     booru.Credentials
 	ProxyString     string
 	WorkerCount     uint
 	OutputDir       string
@@ -47,12 +54,47 @@ type Config struct {
 	DownloadLimitGb float64
 	HTTPClient      *http.Client
 	NoMetadata      bool
+	Posts           string
+	PostsFile       string
+	Pool            int64
+	FavoriteGroup   int64
+	Favorites       string
+	NotesHTML       bool
+	Comments        bool
+	FollowRelations bool
+	Commentary      bool
+	Quality         string
+	Blacklist       string
+	Ratings         string
+	Filters         Filters
+	FiltersFile     string
+	Since           string
+	Until           string
+	MinID           int64
+	MaxID           int64
+	JobsFile        string
+}
+
+// Numeric post filters. Ranges are written as N, >N, >=N, <N, <=N, N..M, N.. or ..M, blank for any
+type Filters struct {
+	Score       string `json:"score"`
+	FavCount    string `json:"favs"`
+	Width       string `json:"width"`
+	Height      string `json:"height"`
+	AspectRatio string `json:"aspect_ratio"`
+	// Video length in seconds
+	Duration    string `json:"duration"`
+	MinFileSize uint   `json:"min_filesize_mb"`
 }
 
 func ParseFlags() *Config {
 	var (
 		version         = flag.Bool("version", false, "Print version information and exit")
 		booruURL        = flag.String("url", "https://danbooru.donmai.us/", "URL to the booru page (blank for danbooru.donmai.us)")
+		engine          = flag.String("engine", "", fmt.Sprintf("Set booru engine (%s) (blank to detect automatically)", strings.Join(booru.ProviderNames(), "|")))
+		login           = flag.String("login", "", "Set login (user name) to authenticate with")
+		apiKey          = flag.String("api-key", "", "Set API key to authenticate with (password hash for moebooru, login token for szurubooru)")
+		userID          = flag.String("user-id", "", "Set user ID to authenticate with (gelbooru)")
 		proxyString     = flag.String("proxy", "", "Set proxy connection string")
 		workerCount     = flag.Uint("workers", 8, "Set worker count")
 		outputDir       = flag.String("output", "output", "Set output directory name")
@@ -64,7 +106,32 @@ func ParseFlags() *Config {
 		fromPage        = flag.Uint("from-page", 1, "Set initial page number")
 		maxFileSize     = flag.Uint("max-filesize-mb", 0, "Set max file size in megabytes (0 for no cap)")
 		downloadLimitGb = flag.Float64("download-limit-gb", 0.0, "Set download limit in gigabytes (0 for no cap)")
+		quality         = flag.String("quality", "original", "Set media quality: original, sample, N (largest fitting into NxN, e.g. 720), max-width=N or smallest-above=WxH")
+		ratings         = flag.String("ratings", "", "Save only posts of these ratings: general, sensitive, questionable, explicit or their letters, separated by commas (blank for any)")
+		score           = flag.String("score", "", "Save only posts with score in this range (e.g. >=10, 5..50, <0)")
+		favCount        = flag.String("favs", "", "Save only posts with favorite count in this range (e.g. >=100)")
+		width           = flag.String("width", "", "Save only media with width in pixels in this range (e.g. >=1920)")
+		height          = flag.String("height", "", "Save only media with height in pixels in this range (e.g. 720..2160)")
+		aspectRatio     = flag.String("aspect-ratio", "", "Save only media with width to height ratio in this range (e.g. 1.7..1.8)")
+		duration        = flag.String("duration", "", "Save only videos with length in seconds in this range (e.g. ..30)")
+		minFileSize     = flag.Uint("min-filesize-mb", 0, "Set min file size in megabytes (0 for no minimum)")
+		since           = flag.String("since", "", "Search only for posts uploaded since this date (YYYY-MM-DD or RFC 3339 time)")
+		until           = flag.String("until", "", "Search only for posts uploaded until this date, inclusive (YYYY-MM-DD or RFC 3339 time)")
+		minID           = flag.Int64("min-id", 0, "Search only for posts with IDs not lower than this one (0 for no bound)")
+		maxID           = flag.Int64("max-id", 0, "Search only for posts with IDs not higher than this one (0 for no bound)")
+		filtersFile     = flag.String("filters", "", "Read post filters from this JSON file, filter flags take precedence")
+		blacklist       = flag.String("blacklist", "", "Skip posts matching rules of this danbooru-style blacklist file")
 		noMetadata      = flag.Bool("no-metadata", false, "Do not save image metadata files")
+		posts           = flag.String("posts", "", "Download only these posts: IDs or post page URLs separated by commas or spaces")
+		postsFile       = flag.String("posts-file", "", "Download only posts listed in this file: an ID or a post page URL per line")
+		pool            = flag.Int64("pool", 0, "Download posts of the pool with this ID in order (danbooru)")
+		favoriteGroup   = flag.Int64("favgroup", 0, "Download posts of the favorite group with this ID in order (danbooru)")
+		favorites       = flag.String("favorites", "", "Download favorites of this user (user ID for gelbooru), skipping ones downloaded before")
+		notesHTML       = flag.Bool("notes-html", false, "Also save translation notes as an HTML page showing them over the image (danbooru)")
+		comments        = flag.Bool("comments", false, "Save comments of posts (danbooru, gelbooru)")
+		commentary      = flag.Bool("commentary", false, "Save artist commentary into metadata (danbooru)")
+		followRelations = flag.Bool("follow-relations", false, "Also download parents and children of found posts")
+		jobsFile        = flag.String("jobs", "", "Run searches of this JSON job file, flags set defaults for its jobs")
 	)
 
 	flag.Parse()
@@ -82,8 +149,14 @@ func ParseFlags() *Config {
 	}
 
 	cfg := &Config{
-		Version:         *version,
-		BooruURL:        parsedURL,
+		Version:  *version,
+		BooruURL: parsedURL,
+		Engine:   *engine,
+		Credentials: booru.Credentials{
+			Login:  *login,
+			APIKey: *apiKey,
+			UserID: *userID,
+		},
 		ProxyString:     *proxyString,
 		WorkerCount:     *workerCount,
 		OutputDir:       *outputDir,
@@ -97,6 +170,33 @@ func ParseFlags() *Config {
 		DownloadLimitGb: *downloadLimitGb,
 		HTTPClient:      nil,
 		NoMetadata:      *noMetadata,
+		Quality:         *quality,
+		Blacklist:       *blacklist,
+		Ratings:         *ratings,
+		Filters: Filters{
+			Score:       *score,
+			FavCount:    *favCount,
+			Width:       *width,
+			Height:      *height,
+			AspectRatio: *aspectRatio,
+			Duration:    *duration,
+			MinFileSize: *minFileSize,
+		},
+		FiltersFile:     *filtersFile,
+		Since:           *since,
+		Until:           *until,
+		MinID:           *minID,
+		MaxID:           *maxID,
+		Posts:           *posts,
+		PostsFile:       *postsFile,
+		Pool:            *pool,
+		FavoriteGroup:   *favoriteGroup,
+		Favorites:       *favorites,
+		NotesHTML:       *notesHTML,
+		Comments:        *comments,
+		FollowRelations: *followRelations,
+		Commentary:      *commentary,
+		JobsFile:        *jobsFile,
 	}
 
 	cfg.Apply()
@@ -147,6 +247,147 @@ func ApplyConfig(cfg *Config) {
 	cfg.HTTPClient = client
 }
 
+// Returns post IDs and post page URLs given with Posts and PostsFile.
+// Empty lines and lines starting with # in the file are ignored
+func (c *Config) PostReferences() ([]string, error) {
+	isSeparator := func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}
+
+	references := strings.FieldsFunc(c.Posts, isSeparator)
+
+	if strings.TrimSpace(c.PostsFile) != "" {
+		contents, err := os.ReadFile(c.PostsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range strings.Split(string(contents), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			references = append(references, strings.FieldsFunc(line, isSeparator)...)
+		}
+	}
+
+	return references, nil
+}
+
 func (c *Config) Apply() {
 	ApplyConfig(c)
 }
+
+// Configuration of the tags command
+type TagsConfig struct {
+	BooruURL    *url.URL
+	Engine      string
+	Credentials booru.Credentials
+	ProxyString string
+	MaxRetries  uint
+	Prefix      string
+	Limit       int
+	HTTPClient  *http.Client
+}
+
+// Parses arguments of the tags command: flags followed by a tag prefix
+func ParseTagsFlags(args []string) *TagsConfig {
+	flags := flag.NewFlagSet("tags", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s tags [flags] <prefix>\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+
+	var (
+		booruURL    = flags.String("url", "https://danbooru.donmai.us/", "URL to the booru page (blank for danbooru.donmai.us)")
+		engine      = flags.String("engine", "", fmt.Sprintf("Set booru engine (%s) (blank to detect automatically)", strings.Join(booru.ProviderNames(), "|")))
+		login       = flags.String("login", "", "Set login (user name) to authenticate with")
+		apiKey      = flags.String("api-key", "", "Set API key to authenticate with (password hash for moebooru, login token for szurubooru)")
+		userID      = flags.String("user-id", "", "Set user ID to authenticate with (gelbooru)")
+		proxyString = flags.String("proxy", "", "Set proxy connection string")
+		maxRetries  = flags.Uint("max-retries", 3, "Set max http request retry count")
+		limit       = flags.Int("limit", booru.DefaultTagSearchLimit, "Set max amount of found tags")
+	)
+
+	flags.Parse(args)
+
+	// Status messages must not mix with printed tags
+	logger.SetOutput(os.Stderr)
+
+	parsedURL, err := url.Parse(*booruURL)
+	if err != nil {
+		logger.Error("[Config] %s is not a valid URL: %s", *booruURL, err)
+		os.Exit(1)
+	}
+
+	cfg := &TagsConfig{
+		BooruURL: parsedURL,
+		Engine:   *engine,
+		Credentials: booru.Credentials{
+			Login:  *login,
+			APIKey: *apiKey,
+			UserID: *userID,
+		},
+		ProxyString: *proxyString,
+		MaxRetries:  *maxRetries,
+		Prefix:      strings.Join(flags.Args(), "_"),
+		Limit:       *limit,
+	}
+
+	proxy.MAXRETRIES = cfg.MaxRetries
+
+	if strings.TrimSpace(cfg.ProxyString) != "" {
+		cfg.HTTPClient, err = proxy.NewProxyClient(cfg.ProxyString)
+		if err != nil {
+			logger.Error("[Config] Failed to create proxy client: %s", err)
+			os.Exit(1)
+		}
+	} else {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	return cfg
+}
+
+// Reads filters from a JSON file
+func LoadFilters(path string) (Filters, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return Filters{}, err
+	}
+
+	var filters Filters
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&filters)
+	if err != nil {
+		return Filters{}, err
+	}
+
+	return filters, nil
+}
+
+// Returns filters with the ones set in override replacing these
+func (f Filters) Merge(override Filters) Filters {
+	pick := func(value string, overriding string) string {
+		if strings.TrimSpace(overriding) != "" {
+			return overriding
+		}
+		return value
+	}
+
+	merged := Filters{
+		Score:       pick(f.Score, override.Score),
+		FavCount:    pick(f.FavCount, override.FavCount),
+		Width:       pick(f.Width, override.Width),
+		Height:      pick(f.Height, override.Height),
+		AspectRatio: pick(f.AspectRatio, override.AspectRatio),
+		Duration:    pick(f.Duration, override.Duration),
+		MinFileSize: f.MinFileSize,
+	}
+	if override.MinFileSize != 0 {
+		merged.MinFileSize = override.MinFileSize
+	}
+
+	return merged
+}
@@ -0,0 +1,219 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Searches to run one after another (or interleaved) sharing workers, rate limit and HTTP client
+type JobFile struct {
+	// Search for posts of all jobs at once instead of one job after another
+	Interleave bool `json:"interleave"`
+	// Path to save the summary of the run to as JSON, none saved if blank
+	Summary string     `json:"summary"`
+	Jobs    []BatchJob `json:"jobs"`
+}
+
+// A search of a job file. Unset settings take values of the configuration
+type BatchJob struct {
+	// Named after its position in the file if blank
+	Name string `json:"name"`
+	Tags string `json:"tags"`
+	// Downloaded instead of posts found by tags, as with flags of the same names
+	Posts         string `json:"posts"`
+	PostsFile     string `json:"posts_file"`
+	Favorites     string `json:"favorites"`
+	Pool          int64  `json:"pool"`
+	FavoriteGroup int64  `json:"favgroup"`
+	// Relative to the output directory, job name if blank
+	Output          string  `json:"output"`
+	FromPage        uint    `json:"from_page"`
+	ImagesOnly      bool    `json:"only_images"`
+	VideosOnly      bool    `json:"only_videos"`
+	MaxFileSize     uint    `json:"max_filesize_mb"`
+	DownloadLimitGb float64 `json:"download_limit_gb"`
+	// Amount of posts to save before the job is done, 0 for no cap
+	MaxPosts    uint    `json:"max_posts"`
+	Quality     string  `json:"quality"`
+	Ratings     string  `json:"ratings"`
+	Blacklist   string  `json:"blacklist"`
+	Filters     Filters `json:"filters"`
+	FiltersFile string  `json:"filters_file"`
+	Since       string  `json:"since"`
+	Until       string  `json:"until"`
+	MinID       int64   `json:"min_id"`
+	MaxID       int64   `json:"max_id"`
+}
+
+// Reads a job file, naming jobs without names
+func LoadJobFile(path string) (*JobFile, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobFile JobFile
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&jobFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(jobFile.Jobs) == 0 {
+		return nil, fmt.Errorf("no jobs")
+	}
+
+	names := make(map[string]bool, len(jobFile.Jobs))
+	for i := range jobFile.Jobs {
+		job := &jobFile.Jobs[i]
+		job.Name = strings.TrimSpace(job.Name)
+		if job.Name == "" {
+			job.Name = fmt.Sprintf("job%d", i+1)
+		}
+
+		if names[job.Name] {
+			return nil, fmt.Errorf("job %d: name %q is taken by another job", i+1, job.Name)
+		}
+		names[job.Name] = true
+
+		if job.sources() > 1 {
+			return nil, fmt.Errorf("job %q: tags, posts, favorites and pools or favorite groups are downloaded by separate jobs", job.Name)
+		}
+	}
+
+	return &jobFile, nil
+}
+
+// Returns the amount of things the job downloads out of tags, posts, favorites and collections
+func (job BatchJob) sources() int {
+	set := []bool{
+		strings.TrimSpace(job.Tags) != "",
+		strings.TrimSpace(job.Posts) != "" || strings.TrimSpace(job.PostsFile) != "",
+		strings.TrimSpace(job.Favorites) != "",
+		job.Pool != 0 || job.FavoriteGroup != 0,
+	}
+
+	sources := 0
+	for _, isSet := range set {
+		if isSet {
+			sources++
+		}
+	}
+	return sources
+}
+
+// Reports whether posts are searched for by tags, rather than given, favorites or collections
+func (c *Config) SearchesTags() bool {
+	return strings.TrimSpace(c.Posts) == "" && strings.TrimSpace(c.PostsFile) == "" &&
+		strings.TrimSpace(c.Favorites) == "" && c.Pool == 0 && c.FavoriteGroup == 0
+}
+
+// Returns filters read from FiltersFile with the ones set by flags replacing these
+func (c *Config) PostFilters() (Filters, error) {
+	if strings.TrimSpace(c.FiltersFile) == "" {
+		return c.Filters, nil
+	}
+
+	fileFilters, err := LoadFilters(c.FiltersFile)
+	if err != nil {
+		return Filters{}, fmt.Errorf("%s: %w", c.FiltersFile, err)
+	}
+
+	return fileFilters.Merge(c.Filters), nil
+}
+
+// Returns configuration of a search for job. Filters of the job take precedence
+// over its filters file, which takes precedence over filters of the configuration
+func (c *Config) ForJob(job BatchJob) (*Config, error) {
+	jobConfig := *c
+
+	jobConfig.Tags = job.Tags
+	jobConfig.Posts = job.Posts
+	jobConfig.PostsFile = job.PostsFile
+	jobConfig.Pool = job.Pool
+	jobConfig.FavoriteGroup = job.FavoriteGroup
+	jobConfig.Favorites = strings.TrimSpace(job.Favorites)
+	jobConfig.JobsFile = ""
+
+	output := strings.TrimSpace(job.Output)
+	if output == "" {
+		output = job.Name
+	}
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(c.OutputDir, output)
+	}
+	jobConfig.OutputDir = output
+
+	if job.FromPage != 0 {
+		jobConfig.FromPage = job.FromPage
+	}
+	jobConfig.ImagesOnly = c.ImagesOnly || job.ImagesOnly
+	jobConfig.VideosOnly = c.VideosOnly || job.VideosOnly
+	if job.MaxFileSize != 0 {
+		jobConfig.MaxFileSize = job.MaxFileSize
+	}
+	if job.DownloadLimitGb != 0 {
+		jobConfig.DownloadLimitGb = job.DownloadLimitGb
+	}
+	if strings.TrimSpace(job.Quality) != "" {
+		jobConfig.Quality = job.Quality
+	}
+	if strings.TrimSpace(job.Ratings) != "" {
+		jobConfig.Ratings = job.Ratings
+	}
+	if strings.TrimSpace(job.Blacklist) != "" {
+		jobConfig.Blacklist = job.Blacklist
+	}
+	if strings.TrimSpace(job.Since) != "" {
+		jobConfig.Since = job.Since
+	}
+	if strings.TrimSpace(job.Until) != "" {
+		jobConfig.Until = job.Until
+	}
+	if job.MinID != 0 {
+		jobConfig.MinID = job.MinID
+	}
+	if job.MaxID != 0 {
+		jobConfig.MaxID = job.MaxID
+	}
+
+	filters, err := c.PostFilters()
+	if err != nil {
+		return nil, err
+	}
+	jobFilters := job.Filters
+	if strings.TrimSpace(job.FiltersFile) != "" {
+		fileFilters, err := LoadFilters(job.FiltersFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", job.FiltersFile, err)
+		}
+		jobFilters = fileFilters.Merge(job.Filters)
+	}
+	jobConfig.Filters = filters.Merge(jobFilters)
+	jobConfig.FiltersFile = ""
+
+	return &jobConfig, nil
+}
@@ -0,0 +1,230 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"Unbewohnte/gobooru-downloader/internal/booru"
+	"Unbewohnte/gobooru-downloader/internal/config"
+	"Unbewohnte/gobooru-downloader/internal/logger"
+)
+
+// Upload times and IDs searched posts have to be within
+type searchBounds struct {
+	// Zero if unbounded, until is exclusive
+	since time.Time
+	until time.Time
+	// Zero if unbounded, both inclusive
+	minID int64
+	maxID int64
+}
+
+// Layouts of bound dates, dates without time are taken in local time
+var boundLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Parses a bound date. A date without time stands for the start of the day,
+// or for the end of it if endOfDay is set
+func parseBoundDate(value string, endOfDay bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range boundLayouts {
+		date, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			continue
+		}
+
+		if layout == "2006-01-02" && endOfDay {
+			date = date.AddDate(0, 0, 1)
+		}
+		return date, nil
+	}
+
+	return time.Time{}, fmt.Errorf("%q is neither a YYYY-MM-DD date nor an RFC 3339 time", value)
+}
+
+func newSearchBounds(cfg *config.Config) (searchBounds, error) {
+	bounds := searchBounds{
+		minID: cfg.MinID,
+		maxID: cfg.MaxID,
+	}
+
+	var err error
+	if strings.TrimSpace(cfg.Since) != "" {
+		bounds.since, err = parseBoundDate(cfg.Since, false)
+		if err != nil {
+			return searchBounds{}, err
+		}
+	}
+	if strings.TrimSpace(cfg.Until) != "" {
+		bounds.until, err = parseBoundDate(cfg.Until, true)
+		if err != nil {
+			return searchBounds{}, err
+		}
+	}
+
+	if !bounds.since.IsZero() && !bounds.until.IsZero() && !bounds.since.Before(bounds.until) {
+		return searchBounds{}, fmt.Errorf("since date is not before until date")
+	}
+	if bounds.minID != 0 && bounds.maxID != 0 && bounds.minID > bounds.maxID {
+		return searchBounds{}, fmt.Errorf("min ID is higher than max ID")
+	}
+
+	return bounds, nil
+}
+
+func (bounds searchBounds) isSet() bool {
+	return bounds != searchBounds{}
+}
+
+func (bounds searchBounds) hasDates() bool {
+	return !bounds.since.IsZero() || !bounds.until.IsZero()
+}
+
+func (bounds searchBounds) hasIDs() bool {
+	return bounds.minID != 0 || bounds.maxID != 0
+}
+
+// Reports whether post is within bounds. Posts of unknown upload time are within date bounds
+func (bounds searchBounds) contains(post booru.Post) bool {
+	id := post.PostID()
+	if bounds.minID != 0 && id < bounds.minID {
+		return false
+	}
+	if bounds.maxID != 0 && id > bounds.maxID {
+		return false
+	}
+
+	createdAt := post.CreatedTime()
+	if createdAt.IsZero() {
+		return true
+	}
+	if !bounds.since.IsZero() && createdAt.Before(bounds.since) {
+		return false
+	}
+	if !bounds.until.IsZero() && !createdAt.Before(bounds.until) {
+		return false
+	}
+
+	return true
+}
+
+// Reports whether no posts within bounds can follow a page of posts ordered by descending ID:
+// either IDs went below the min ID, or every post of the page was uploaded before the since date
+func (bounds searchBounds) passed(posts []booru.Post) bool {
+	if bounds.minID != 0 {
+		for _, post := range posts {
+			if post.PostID() != 0 && post.PostID() < bounds.minID {
+				return true
+			}
+		}
+	}
+
+	if bounds.since.IsZero() {
+		return false
+	}
+
+	// Upload times follow IDs only roughly, so a single older post proves nothing
+	dated := 0
+	for _, post := range posts {
+		createdAt := post.CreatedTime()
+		if createdAt.IsZero() {
+			continue
+		}
+		if !createdAt.Before(bounds.since) {
+			return false
+		}
+		dated++
+	}
+
+	return dated != 0
+}
+
+// Looks for the ID to start a search for tags ordered by ID at, so that posts uploaded after
+// the until date of t are not paged through: the ID the page holding the last of these posts
+// starts at. Halves the range of IDs below before (if non-zero) with a page request at a time.
+// Returns 0 if there is nothing to skip or the upload times are unknown
+func (d *Downloader) seekUntil(site *booru.Site, t *task, tags string, before int64) int64 {
+	// Returns the newest and the oldest post of the page before ID
+	probe := func(before int64) (newest booru.Post, oldest booru.Post, err error) {
+		if err := d.limiter.Wait(context.Background()); err != nil {
+			return nil, nil, err
+		}
+
+		posts, err := site.GetPosts(booru.Cursor{Page: 1, BeforeID: before}, tags)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, post := range posts {
+			if newest == nil || post.PostID() > newest.PostID() {
+				newest = post
+			}
+			if oldest == nil || post.PostID() < oldest.PostID() {
+				oldest = post
+			}
+		}
+
+		return newest, oldest, nil
+	}
+	after := func(post booru.Post) bool {
+		return !post.CreatedTime().Before(t.bounds.until)
+	}
+
+	newest, _, err := probe(before)
+	if err != nil {
+		logger.Warning("%s Failed to look for posts uploaded before %s: %s", t.label(), t.config.Until, err)
+		return 0
+	}
+	if newest == nil || newest.CreatedTime().IsZero() || !after(newest) {
+		return 0
+	}
+	logger.Info("%s Looking for posts uploaded before %s...", t.label(), t.config.Until)
+
+	// The newest post below high is uploaded after the until date, the one below low is not
+	low, high := int64(1), newest.PostID()+1
+	for high-low > 1 {
+		middle := low + (high-low)/2
+
+		newest, oldest, err := probe(middle)
+		if err != nil {
+			logger.Warning("%s Failed to look for posts uploaded before %s: %s", t.label(), t.config.Until, err)
+			return high
+		}
+
+		switch {
+		case newest == nil || !after(newest):
+			low = middle
+		case !after(oldest):
+			// The page goes past the until date
+			return middle
+		default:
+			high = middle
+		}
+	}
+
+	return high
+}
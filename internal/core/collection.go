@@ -0,0 +1,177 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"Unbewohnte/gobooru-downloader/internal/booru"
+	"Unbewohnte/gobooru-downloader/internal/files"
+	"Unbewohnte/gobooru-downloader/internal/logger"
+)
+
+// Name of the manifest file saved alongside collection media
+const collectionManifestName string = "manifest.json"
+
+type collectionEntry struct {
+	Position int    `json:"position"`
+	PostID   int64  `json:"post_id"`
+	File     string `json:"file"`
+}
+
+// Describes a downloaded collection and the order of its media
+type collectionManifest struct {
+	Kind        booru.CollectionKind `json:"kind"`
+	ID          int64                `json:"id"`
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Category    string               `json:"category,omitempty"`
+	FromHost    string               `json:"from_host"`
+	Posts       []collectionEntry    `json:"posts"`
+}
+
+// Keeps track of media saved for a collection
+type collectionDownload struct {
+	mutex     sync.Mutex
+	wg        sync.WaitGroup
+	directory string
+	manifest  collectionManifest
+}
+
+// Remembers the name of the file saved for the post at every position it takes
+func (download *collectionDownload) record(postID int64, fileName string) {
+	download.mutex.Lock()
+	defer download.mutex.Unlock()
+
+	for i := range download.manifest.Posts {
+		if download.manifest.Posts[i].PostID == postID {
+			download.manifest.Posts[i].File = fileName
+		}
+	}
+}
+
+func (download *collectionDownload) saveManifest() error {
+	download.mutex.Lock()
+	defer download.mutex.Unlock()
+
+	contents, err := json.MarshalIndent(download.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return files.WriteAtomic(filepath.Join(download.directory, collectionManifestName), contents)
+}
+
+// Returns name of the file saved for the post at position of a collection
+func positionedFileName(position int, fileName string) string {
+	return fmt.Sprintf("%04d_%s", position, fileName)
+}
+
+// Downloads posts of a collection in order into its own directory, files are prefixed with
+// their position. A manifest with collection information and order is saved alongside
+func (d *Downloader) downloadCollection(site *booru.Site, t *task, kind booru.CollectionKind, id int64) {
+	// Rate limit collection requests
+	if err := d.limiter.Wait(context.Background()); err != nil {
+		logger.Error("%s Rate limiter error: %s", t.label(), err)
+		return
+	}
+
+	collection, err := site.GetCollection(kind, id)
+	if err != nil {
+		logger.Error("%s Failed to get %s %d: %s", t.label(), kind, id, err)
+		return
+	}
+	logger.Info("%s Downloading %s \"%s\" (%d posts)", t.label(), kind, collection.Name, len(collection.PostIDs))
+
+	download := &collectionDownload{
+		directory: filepath.Join(t.config.OutputDir, fmt.Sprintf("%s_%d", kind, collection.ID)),
+		manifest: collectionManifest{
+			Kind:        collection.Kind,
+			ID:          collection.ID,
+			Name:        collection.Name,
+			Description: collection.Description,
+			Category:    collection.Category,
+			FromHost:    collection.FromHost,
+			Posts:       make([]collectionEntry, len(collection.PostIDs)),
+		},
+	}
+
+	err = os.MkdirAll(download.directory, os.ModePerm)
+	if err != nil {
+		logger.Error("%s Failed to create %s: %s", t.label(), download.directory, err)
+		return
+	}
+
+	// Posts may appear in a collection more than once
+	var uniqueIDs []int64
+	firstPositions := make(map[int64]int)
+	for i, postID := range collection.PostIDs {
+		download.manifest.Posts[i] = collectionEntry{Position: i + 1, PostID: postID}
+		if _, seen := firstPositions[postID]; !seen {
+			firstPositions[postID] = i + 1
+			uniqueIDs = append(uniqueIDs, postID)
+		}
+	}
+
+	posts, err := site.GetPostsByID(uniqueIDs, d.limiter)
+	if err != nil {
+		logger.Error("%s Failed to get posts of %s %d: %s", t.label(), kind, id, err)
+	}
+	if len(posts) < len(uniqueIDs) {
+		logger.Warning("%s %d posts of %s %d are unavailable", t.label(), len(uniqueIDs)-len(posts), kind, id)
+	}
+
+	// Submit in reading order
+	byID := make(map[int64]booru.Post, len(posts))
+	for _, post := range posts {
+		byID[post.PostID()] = post
+	}
+
+	// Repeated posts are saved once, under their first position
+	for _, postID := range uniqueIDs {
+		post, ok := byID[postID]
+		if !ok {
+			continue
+		}
+
+		download.wg.Add(1)
+		job := NewJob(site, post)
+		job.Directory = download.directory
+		job.Position = firstPositions[postID]
+		job.done = &download.wg
+		job.onSaved = func(fileName string) {
+			download.record(postID, fileName)
+		}
+		if !d.submitJob(t, job) {
+			download.wg.Done()
+			break
+		}
+	}
+
+	download.wg.Wait()
+	err = download.saveManifest()
+	if err != nil {
+		logger.Error("%s Failed to save manifest of %s %d: %s", t.label(), kind, id, err)
+	}
+}
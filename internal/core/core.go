@@ -20,10 +20,15 @@ package core
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -31,6 +36,8 @@ import (
 	"Unbewohnte/gobooru-downloader/internal/booru"
 	"Unbewohnte/gobooru-downloader/internal/config"
 	"Unbewohnte/gobooru-downloader/internal/logger"
+	"Unbewohnte/gobooru-downloader/internal/proxy"
+	"Unbewohnte/gobooru-downloader/internal/query"
 	"Unbewohnte/gobooru-downloader/internal/workerpool"
 
 	"golang.org/x/time/rate"
@@ -38,12 +45,18 @@ import (
 
 const VERSION string = "0.3.1"
 
+func init() {
+	// Identify ourselves with a descriptive User-Agent
+	proxy.USERAGENT = fmt.Sprintf("gobooru-downloader/%s (https://github.com/Unbewohnte/gobooru-downloader)", VERSION)
+}
+
 type Downloader struct {
 	client       *http.Client
 	limiter      *rate.Limiter
 	pool         *workerpool.Pool[Job, Result]
 	config       *config.Config
 	shutdown     chan struct{}
+	stopOnce     sync.Once
 	wg           sync.WaitGroup
 	downloadedGB float64
 	signalChan   chan os.Signal
@@ -82,57 +95,447 @@ func (d *Downloader) Run() error {
 	d.lastBytes = 0
 	d.downloadedGB = 0.0
 
+	// Find out which engine serves the booru
+	site, err := booru.NewSite(*d.config.BooruURL, d.config.Engine, d.config.Credentials, d.client)
+	if err != nil {
+		logger.Error("[Main] Failed to recognize %s: %s", d.config.BooruURL.Hostname(), err)
+		return err
+	}
+	site.Limiter = d.limiter
+	logger.Info("[Main] Using %s engine for %s", site.Provider.Name(), site.URL.Hostname())
+
+	var (
+		jobFile *config.JobFile
+		tasks   []*task
+	)
+	if strings.TrimSpace(d.config.JobsFile) != "" {
+		jobFile, err = config.LoadJobFile(d.config.JobsFile)
+		if err != nil {
+			logger.Error("[Main] Failed to load jobs %s: %s", d.config.JobsFile, err)
+			return err
+		}
+
+		for _, job := range jobFile.Jobs {
+			jobConfig, err := d.config.ForJob(job)
+			if err != nil {
+				logger.Error("[Main] Job %s: %s", job.Name, err)
+				return err
+			}
+
+			t, err := newTask(site, jobConfig, job.Name)
+			if err != nil {
+				logger.Error("[Main] Job %s: %s", job.Name, err)
+				return err
+			}
+			t.maxPosts = job.MaxPosts
+			tasks = append(tasks, t)
+		}
+		logger.Info("[Main] Loaded %d jobs", len(tasks))
+	} else {
+		t, err := newTask(site, d.config, "")
+		if err != nil {
+			logger.Error("[Main] %s", err)
+			return err
+		}
+		tasks = append(tasks, t)
+	}
+
 	// Start worker pool with our processing function
 	d.pool.Start(d.workerFunc)
 
 	// Handle results in background
 	go d.handleResults()
 
-	// Main download loop
-	galleryURL := d.config.BooruURL
-	currentPage := d.config.FromPage
+	if jobFile != nil {
+		d.runJobs(site, tasks, jobFile.Interleave)
+	} else {
+		err = d.runTask(site, tasks[0])
+		if err != nil {
+			d.Stop()
+			return err
+		}
+	}
+
+	// Wait for every result before summing them up
+	err = d.Stop()
+	summary := d.summarize(tasks)
+	if jobFile != nil && strings.TrimSpace(jobFile.Summary) != "" {
+		if err := summary.save(jobFile.Summary); err != nil {
+			logger.Error("[Main] Failed to save summary %s: %s", jobFile.Summary, err)
+		}
+	}
+
+	return err
+}
+
+// Downloads whatever the configuration of t asks for: given posts, favorites,
+// collections or posts found by tags
+func (d *Downloader) runTask(site *booru.Site, t *task) error {
+	references, err := t.config.PostReferences()
+	if err != nil {
+		logger.Error("%s Failed to read posts to download: %s", t.label(), err)
+		return err
+	}
+
+	switch {
+	case len(references) != 0:
+		d.downloadPosts(site, t, references)
+	case t.config.Favorites != "":
+		d.downloadFavorites(site, t, t.config.Favorites)
+	case t.config.Pool != 0 || t.config.FavoriteGroup != 0:
+		if t.config.Pool != 0 {
+			d.downloadCollection(site, t, booru.CollectionPool, t.config.Pool)
+		}
+		if t.config.FavoriteGroup != 0 {
+			d.downloadCollection(site, t, booru.CollectionFavoriteGroup, t.config.FavoriteGroup)
+		}
+	default:
+		d.downloadSearch(site, t)
+	}
+
+	return nil
+}
+
+// Runs job tasks one after another, or all at once if interleaved.
+// Either way their posts are saved by the same workers
+func (d *Downloader) runJobs(site *booru.Site, tasks []*task, interleave bool) {
+	// Failures are logged by runTask and do not stop other jobs
+	runJob := func(t *task) {
+		if t.config.SearchesTags() {
+			logger.Info("%s Searching for %q", t.label(), t.config.Tags)
+		}
+		d.runTask(site, t)
+	}
+
+	if !interleave {
+		for _, t := range tasks {
+			if !d.IsRunning() {
+				return
+			}
+			runJob(t)
+		}
+		return
+	}
+
+	var jobs sync.WaitGroup
+	for _, t := range tasks {
+		jobs.Add(1)
+		go func(t *task) {
+			defer jobs.Done()
+			runJob(t)
+		}(t)
+	}
+	jobs.Wait()
+}
+
+// Returns key identifying post with given ID on site
+func postKey(site *booru.Site, id int64) string {
+	return fmt.Sprintf("%s/%d", site.URL.Host, id)
+}
+
+// Submits post of site to worker pool unless t submitted it before. When following relations,
+// the family of post is submitted along. Returns false if downloader is shutting down
+func (d *Downloader) submit(site *booru.Site, t *task, post booru.Post) bool {
+	if t.config.FollowRelations {
+		for _, member := range d.familyOf(site, t, post) {
+			if !d.submitOnce(site, t, member) {
+				return false
+			}
+		}
+	}
+
+	return d.submitOnce(site, t, post)
+}
+
+func (d *Downloader) submitOnce(site *booru.Site, t *task, post booru.Post) bool {
+	// Posts without known IDs can not be told apart
+	if post.PostID() != 0 {
+		key := postKey(site, post.PostID())
+		if t.submitted[key] {
+			return true
+		}
+		t.submitted[key] = true
+	}
+
+	return d.submitJob(t, NewJob(site, post))
+}
+
+// Returns posts of the family post belongs to, unless t looked it up before
+func (d *Downloader) familyOf(site *booru.Site, t *task, post booru.Post) []booru.Post {
+	rootID := booru.FamilyRootID(post)
+	if rootID == 0 {
+		return nil
+	}
+
+	key := postKey(site, rootID)
+	if t.families[key] {
+		return nil
+	}
+	t.families[key] = true
+
+	family, err := site.GetFamily(post, d.limiter)
+	if err != nil {
+		logger.Error("[Main] Failed to get relatives of post %d: %s", post.PostID(), err)
+		return nil
+	}
+	logger.Info("[Main] Following relatives of post %d (%d posts in family)", post.PostID(), len(family))
+
+	return family
+}
+
+// Submits job of t to worker pool. Returns false if downloader is shutting down
+func (d *Downloader) submitJob(t *task, job Job) bool {
+	job.task = t
+
+	select {
+	case <-d.shutdown:
+		return false
+	default:
+		d.wg.Add(1)
+		d.pool.Submit(job)
+		return true
+	}
+}
+
+// Splits tag query into tags the site is searched with and a query matched locally, looking
+// up tag post counts to search with the most selective tags. Reserved tags of the search are
+// left for other terms
+func (d *Downloader) splitQuery(site *booru.Site, t *task, reserved int) (string, *query.Query) {
+	limit, _ := site.TagLimit()
+	if limit > 0 {
+		limit -= reserved
+	}
+	search := t.tagQuery.Split(limit, site.OrSyntax(), func(tag string) (int, bool) {
+		// Rate limit tag requests
+		if err := d.limiter.Wait(context.Background()); err != nil {
+			logger.Error("[Main] Rate limiter error: %s", err)
+			return 0, false
+		}
+
+		count, known, err := site.TagPostCount(tag)
+		if err != nil {
+			logger.Warning("%s Failed to look up post count of %s: %s", t.label(), tag, err)
+			return 0, false
+		}
+
+		return count, known
+	})
+
+	if search.Unnarrowed {
+		logger.Warning(
+			"%s None of the tags of %q fit into the search, every post found with %q is looked through",
+			t.label(), t.tagQuery, search.Tags,
+		)
+	}
+	if len(search.Unordered) != 0 {
+		logger.Warning("%s No room for %s in the search, results are not ordered as asked", t.label(), strings.Join(search.Unordered, " "))
+	}
+	if search.Local != nil {
+		logger.Info("%s Searching for %q, matching %q locally", t.label(), search.Tags, search.Local)
+	}
+
+	return search.Tags, search.Local
+}
+
+// Downloads posts found with tags of t page after page until there are none left or
+// t reaches its limits. Parts of its tag query the site can not search for are matched locally
+func (d *Downloader) downloadSearch(site *booru.Site, t *task) {
+	cursor := booru.PageCursor(t.config.FromPage)
+
+	tags := t.config.Tags
+	limit, _ := site.TagLimit()
+
+	// Boorus searching by upload time are given the date bounds, which take a tag of the search
+	datesSearched := t.bounds.hasDates() && site.SearchesDates() && limit != 1
+	if t.tagQuery == nil && limit != 0 && len(strings.Fields(tags)) >= limit {
+		datesSearched = false
+	}
+
+	var localQuery *query.Query
+	if t.tagQuery != nil {
+		reserved := 0
+		if datesSearched {
+			reserved = 1
+		}
+		tags, localQuery = d.splitQuery(site, t, reserved)
+	}
+	if datesSearched {
+		tags = site.WithDates(tags, t.bounds.since, t.bounds.until)
+	}
+
+	// Results ordered by ID can be started at the max ID and left once they pass the bounds
+	ordered := site.OrderedByID(tags)
+	if t.bounds.isSet() && !ordered && !(datesSearched && !t.bounds.hasIDs()) {
+		logger.Warning("%s Results are not ordered by ID, going through all of them to find posts within bounds", t.label())
+	}
+	if ordered && t.config.FromPage <= 1 {
+		if t.bounds.maxID != 0 {
+			cursor.BeforeID = t.bounds.maxID + 1
+		}
+		// Otherwise the search would go through every post uploaded after the until date
+		if !datesSearched && !t.bounds.until.IsZero() {
+			if start := d.seekUntil(site, t, tags, cursor.BeforeID); start != 0 {
+				cursor.BeforeID = start
+			}
+		}
+	}
 
 	for {
 		select {
 		case <-d.shutdown:
 			logger.Info("Shutting down...")
-			return nil
+			return
 		default:
-			logger.Info("[Main] On page %d", currentPage)
+			if t.limitReached() {
+				logger.Info("%s Reached the download limit, finishing...", t.label())
+				return
+			}
+
+			logger.Info("%s On %s", t.label(), cursor)
 
 			// Rate limit page requests
 			if err := d.limiter.Wait(context.Background()); err != nil {
-				logger.Error("[Main] Rate limiter error: %s", err)
+				logger.Error("%s Rate limiter error: %s", t.label(), err)
 				continue
 			}
 
 			// Get posts from current page
-			posts, err := booru.GetPosts(*galleryURL, currentPage, d.config.Tags, d.client)
+			posts, err := site.GetPosts(cursor, tags)
 			if err != nil {
-				logger.Error("[Main] Failed after retries: %s...", err)
+				logger.Error("%s Failed after retries: %s...", t.label(), err)
 				continue
 			}
 
-			// Submit posts to worker pool
+			if len(posts) == 0 {
+				logger.Info("%s No more posts, finishing...", t.label())
+				return
+			}
+
+			// Submit posts to worker pool. Results may shift while paging,
+			// but posts already submitted are not submitted again
+			outside := 0
 			for _, post := range posts {
-				select {
-				case <-d.shutdown:
-					return nil
-				default:
-					d.wg.Add(1)
-					d.pool.Submit(NewJob(post))
+				if !t.bounds.contains(post) {
+					outside++
+					continue
+				}
+
+				if !localQuery.Matches(post) {
+					logger.Info("%s Skipping post %d, it does not match %q", t.label(), post.PostID(), localQuery)
+					continue
 				}
+
+				if t.limitReached() {
+					logger.Info("%s Reached the download limit, finishing...", t.label())
+					return
+				}
+
+				if !d.submit(site, t, post) {
+					return
+				}
+			}
+
+			if outside != 0 {
+				logger.Info("%s Skipped %d posts outside of bounds", t.label(), outside)
 			}
 
-			currentPage++
+			if ordered && t.bounds.passed(posts) {
+				logger.Info("%s Passed the bounds, finishing...", t.label())
+				return
+			}
+
+			cursor = site.NextCursor(cursor, posts, tags)
 		}
 	}
 }
 
+// Downloads posts given by their IDs or post page URLs. IDs refer to posts of site,
+// URLs may point to any supported booru
+func (d *Downloader) downloadPosts(site *booru.Site, t *task, references []string) {
+	sites := map[string]*booru.Site{
+		site.URL.Host: site,
+	}
+	seen := make(map[string]bool)
+
+	for _, reference := range references {
+		if seen[reference] {
+			continue
+		}
+		seen[reference] = true
+
+		postSite, id, err := d.resolvePostReference(site, sites, reference)
+		if err != nil {
+			logger.Error("%s Skipping %s: %s", t.label(), reference, err)
+			continue
+		}
+
+		// Rate limit post requests
+		if err := d.limiter.Wait(context.Background()); err != nil {
+			logger.Error("%s Rate limiter error: %s", t.label(), err)
+			continue
+		}
+
+		post, err := postSite.GetPost(id)
+		if err != nil {
+			logger.Error("%s Failed to get post %d from %s: %s", t.label(), id, postSite.URL.Hostname(), err)
+			continue
+		}
+
+		if !d.submit(postSite, t, post) {
+			logger.Info("Shutting down...")
+			return
+		}
+	}
+}
+
+// Returns site and post ID a reference (a bare post ID or a post page URL) points at.
+// Sites of other boorus are recognized once and kept in sites
+func (d *Downloader) resolvePostReference(site *booru.Site, sites map[string]*booru.Site, reference string) (*booru.Site, int64, error) {
+	id, err := strconv.ParseInt(reference, 10, 64)
+	if err == nil {
+		return site, id, nil
+	}
+
+	if !strings.Contains(reference, "://") {
+		reference = "https://" + reference
+	}
+	postURL, err := url.Parse(reference)
+	if err != nil || postURL.Host == "" {
+		return nil, 0, fmt.Errorf("neither a post ID nor a post URL")
+	}
+
+	postSite, ok := sites[postURL.Host]
+	if !ok {
+		siteURL := url.URL{Scheme: postURL.Scheme, Host: postURL.Host, Path: "/"}
+		if strings.HasSuffix(postURL.Path, "index.php") {
+			// Gelbooru 0.2 instances may live in a subdirectory
+			siteURL.Path = postURL.Path
+		}
+
+		// Credentials belong to the configured booru only
+		postSite, err = booru.NewSite(siteURL, "", booru.Credentials{}, d.client)
+		if err != nil {
+			return nil, 0, err
+		}
+		postSite.Limiter = d.limiter
+		logger.Info("[Main] Using %s engine for %s", postSite.Provider.Name(), postSite.URL.Hostname())
+		sites[postURL.Host] = postSite
+	}
+
+	id, ok = postSite.ParsePostURL(*postURL)
+	if !ok {
+		return nil, 0, fmt.Errorf("not a %s post URL", postSite.Provider.Name())
+	}
+
+	return postSite, id, nil
+}
+
 func (d *Downloader) Stop() error {
-	close(d.shutdown)
-	d.wg.Wait()
-	d.pool.Shutdown()
+	d.stopOnce.Do(func() {
+		close(d.shutdown)
+		d.wg.Wait()
+		d.pool.Shutdown()
+	})
 	return nil
 }
 
@@ -143,51 +546,193 @@ func (d *Downloader) handleSignals() {
 	os.Exit(0)
 }
 
-func (d *Downloader) workerFunc(j Job) Result {
+func (d *Downloader) workerFunc(j Job) (result Result) {
+	if j.done != nil {
+		defer j.done.Done()
+	}
+
+	t := j.task
+	var (
+		reserved     bool
+		reservedSize uint64
+	)
+	defer func() {
+		t.record(result, reserved, reservedSize)
+	}()
+
+	directory := j.Directory
+	if directory == "" {
+		directory = t.config.OutputDir
+	}
+
 	// Rate limit worker requests
 	if err := d.limiter.Wait(context.Background()); err != nil {
 		logger.Error("[Worker] Rate limiter error: %s", err)
 		return NewResult(false, false, nil)
 	}
 
+	// Pick media variant before anything looks at media
+	t.quality.Apply(j.Post)
+
 	mediaName := path.Base(j.Post.MediaURL())
 
-	// Apply filters
-	if d.config.ImagesOnly && !j.Post.IsImage() {
-		logger.Info("[Worker] Skipping %s, it's not an image", mediaName)
+	// Skips the post filtered out for reason
+	filteredOut := func(reason string) Result {
+		logger.Info("[Worker] Skipping %s, %s", mediaName, reason)
+		if j.onFiltered != nil {
+			j.onFiltered(reason)
+		}
 		return NewResult(false, true, j.Post.Metadata())
 	}
 
-	if d.config.VideosOnly && !j.Post.IsVideo() {
-		logger.Info("[Worker] Skipping %s, it's not a video", mediaName)
-		return NewResult(false, true, j.Post.Metadata())
+	// Apply filters
+	if t.config.ImagesOnly && !j.Post.IsImage() {
+		return filteredOut("it's not an image")
+	}
+
+	if t.config.VideosOnly && !j.Post.IsVideo() {
+		return filteredOut("it's not a video")
 	}
 
-	if d.config.MaxFileSize != 0 {
-		if j.Post.Size()/1024/1024 > uint64(d.config.MaxFileSize) {
-			logger.Info("[Worker] Skipping %s because it's too large", mediaName)
-			return NewResult(false, true, j.Post.Metadata())
+	if reason := filterPost(t.filters, j.Post); reason != "" {
+		return filteredOut(reason)
+	}
+
+	if len(t.ratings) != 0 && !t.ratings[j.Post.PostRating()] {
+		if j.Post.PostRating() == booru.RatingUnknown {
+			return filteredOut("its rating is unknown")
 		}
+		return filteredOut(fmt.Sprintf("it's rated %s", j.Post.PostRating()))
+	}
+
+	if rule, ok := t.blacklist.Match(j.Post); ok {
+		return filteredOut(fmt.Sprintf("it's blacklisted by %q", rule))
 	}
 
+	// Sizes the booru does not tell are checked once media is saved
+	sizeKnown := j.Post.Size() != 0
+	if t.config.MaxFileSize != 0 && sizeKnown && j.Post.Size()/1024/1024 > uint64(t.config.MaxFileSize) {
+		return filteredOut("it's too large")
+	}
+
+	// Posts being saved by other workers count against the limits as well
+	reservedSize = j.Post.Size()
+	if !t.reserve(reservedSize) {
+		logger.Info("[Worker] Skipping %s, the download limit is reached", mediaName)
+		return NewResult(false, true, j.Post.Metadata())
+	}
+	reserved = true
+
 	// Save media
-	if err := j.Post.SaveMedia(d.config.OutputDir, d.client); err != nil {
+	if err := j.Post.SaveMedia(directory, d.client); err != nil {
 		logger.Error("[Worker] Failed to save %s: %s", mediaName, err)
 		return NewResult(false, false, j.Post.Metadata())
 	}
 
+	fileName := j.Post.Metadata().FileName()
+
+	if !sizeKnown {
+		reason := filterPost(t.filters, j.Post)
+		if t.config.MaxFileSize != 0 && j.Post.Size()/1024/1024 > uint64(t.config.MaxFileSize) {
+			reason = "it's too large"
+		}
+		if reason != "" {
+			if err := os.Remove(filepath.Join(directory, fileName)); err != nil {
+				logger.Error("[Worker] Failed to remove %s: %s", fileName, err)
+			}
+			return filteredOut(reason)
+		}
+	}
+
+	// Prefix collection media with its position to keep the order
+	if j.Position != 0 {
+		positionedName := positionedFileName(j.Position, fileName)
+		err := os.Rename(filepath.Join(directory, fileName), filepath.Join(directory, positionedName))
+		if err != nil {
+			logger.Error("[Worker] Failed to rename %s: %s", fileName, err)
+			return NewResult(false, false, j.Post.Metadata())
+		}
+		fileName = positionedName
+	}
+
+	if j.onSaved != nil {
+		j.onSaved(fileName)
+	}
+
 	// Save metadata if needed
-	if !d.config.NoMetadata {
+	if !t.config.NoMetadata {
+		if t.config.Commentary && j.Site != nil {
+			if err := j.Site.FetchCommentary(j.Post, d.limiter); err != nil {
+				logger.Warning("[Worker] Failed to get artist commentary of post %d: %s", j.Post.PostID(), err)
+			}
+		}
+
 		// Save metadata
-		if err := j.Post.SaveMetadata(d.config.OutputDir); err != nil {
+		if err := j.Post.SaveMetadata(directory); err != nil {
 			logger.Error("[Worker] Failed to save metadata for %s: %s", mediaName, err)
 			return NewResult(false, false, j.Post.Metadata())
 		}
+
+		d.saveNotes(j, directory, fileName)
+	}
+
+	if t.config.Comments {
+		d.saveComments(j, directory)
 	}
 
 	return NewResult(true, false, j.Post.Metadata())
 }
 
+// Saves notes of the post, if there are any, next to its media saved as fileName.
+// Failing to do so is not fatal for the job
+func (d *Downloader) saveNotes(j Job, directory string, fileName string) {
+	if j.Site == nil {
+		return
+	}
+
+	notes, err := j.Site.GetNotes(j.Post, d.limiter)
+	if err != nil {
+		logger.Warning("[Worker] Failed to get notes of post %d: %s", j.Post.PostID(), err)
+		return
+	}
+	if notes == nil {
+		return
+	}
+
+	mediaHash := j.Post.Metadata().Hash
+	if err := notes.Save(directory, mediaHash); err != nil {
+		logger.Warning("[Worker] Failed to save notes of post %d: %s", j.Post.PostID(), err)
+		return
+	}
+
+	if j.task.config.NotesHTML {
+		if err := notes.SaveHTML(directory, mediaHash, fileName); err != nil {
+			logger.Warning("[Worker] Failed to save notes page of post %d: %s", j.Post.PostID(), err)
+		}
+	}
+}
+
+// Saves comments of the post, if there are any, next to its media.
+// Failing to do so is not fatal for the job
+func (d *Downloader) saveComments(j Job, directory string) {
+	if j.Site == nil {
+		return
+	}
+
+	comments, err := j.Site.GetComments(j.Post, d.limiter)
+	if err != nil {
+		logger.Warning("[Worker] Failed to get comments of post %d: %s", j.Post.PostID(), err)
+		return
+	}
+	if comments == nil {
+		return
+	}
+
+	if err := comments.Save(directory, j.Post.Metadata().Hash); err != nil {
+		logger.Warning("[Worker] Failed to save comments of post %d: %s", j.Post.PostID(), err)
+	}
+}
+
 func (d *Downloader) handleResults() {
 	for result := range d.pool.GetResults() {
 		d.totalCount++ // Increment total attempted count
@@ -0,0 +1,296 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"Unbewohnte/gobooru-downloader/internal/booru"
+	"Unbewohnte/gobooru-downloader/internal/files"
+	"Unbewohnte/gobooru-downloader/internal/logger"
+)
+
+type favoriteEntry struct {
+	PostID int64 `json:"post_id"`
+	// Blank if media was not saved (filtered out or failed)
+	File string `json:"file"`
+	// Why the post was filtered out, blank if it was saved or failed to be
+	Filtered string `json:"filtered,omitempty"`
+	// Listed by an interrupted search, so searches go on past it to favorites it did not reach
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// Reports whether the post of entry was dealt with, so it is not tried again
+func (entry favoriteEntry) done() bool {
+	return entry.File != "" || entry.Filtered != ""
+}
+
+// Favorites of a user, newest first
+type favoritesManifest struct {
+	User      string          `json:"user"`
+	FromHost  string          `json:"from_host"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Posts     []favoriteEntry `json:"posts"`
+}
+
+// Returns path of the favorites manifest of user on site
+func favoritesManifestPath(outputDir string, site *booru.Site, user string) string {
+	safeName := func(name string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.' {
+				return r
+			}
+			return '_'
+		}, name)
+	}
+
+	return filepath.Join(
+		outputDir,
+		fmt.Sprintf("favorites_%s_%s.json", safeName(site.URL.Hostname()), safeName(user)),
+	)
+}
+
+// Reads manifest at path. A missing manifest is an empty one
+func loadFavoritesManifest(path string) (*favoritesManifest, error) {
+	var manifest favoritesManifest
+
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(contents, &manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+func (manifest *favoritesManifest) save(path string) error {
+	contents, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return files.WriteAtomic(path, contents)
+}
+
+// Downloads favorites of user not downloaded during previous runs. Boorus ordering favorites
+// by time are only searched up to the newest favorite already known, others are searched in
+// full. Favorites which failed to be saved are tried again, filtered out ones are not.
+// The list of favorites is kept newest first in a manifest in the output directory
+func (d *Downloader) downloadFavorites(site *booru.Site, t *task, user string) {
+	query, byFavoriteTime := site.FavoritesQuery(user)
+	manifestPath := favoritesManifestPath(t.config.OutputDir, site, user)
+
+	manifest, err := loadFavoritesManifest(manifestPath)
+	if err != nil {
+		logger.Error("%s Failed to read favorites manifest %s: %s", t.label(), manifestPath, err)
+		return
+	}
+
+	// Favorites which failed to be saved before are tried again
+	known := make(map[int64]favoriteEntry, len(manifest.Posts))
+	for _, entry := range manifest.Posts {
+		if entry.done() {
+			known[entry.PostID] = entry
+		}
+	}
+	logger.Info(
+		"%s Downloading favorites of %s (%d known, %d to try again)",
+		t.label(), user, len(known), len(manifest.Posts)-len(known),
+	)
+
+	var (
+		// Favorites found during this run in search order
+		found    []favoriteEntry
+		seen     = make(map[int64]bool)
+		outcomes = make(map[int64]favoriteEntry)
+		// Set if the downloader was shut down, before the search was over or after
+		interrupted  bool
+		searchOver   bool
+		outcomeMutex sync.Mutex
+		jobs         sync.WaitGroup
+	)
+
+	// Submits a favorite post, its file or the reason it is filtered out is noted
+	submitFavorite := func(post booru.Post) bool {
+		postID := post.PostID()
+		jobs.Add(1)
+		job := NewJob(site, post)
+		job.done = &jobs
+		job.onSaved = func(fileName string) {
+			outcomeMutex.Lock()
+			outcomes[postID] = favoriteEntry{PostID: postID, File: fileName}
+			outcomeMutex.Unlock()
+		}
+		job.onFiltered = func(reason string) {
+			outcomeMutex.Lock()
+			outcomes[postID] = favoriteEntry{PostID: postID, Filtered: reason}
+			outcomeMutex.Unlock()
+		}
+		if !d.submitJob(t, job) {
+			jobs.Done()
+			return false
+		}
+		return true
+	}
+
+	cursor := booru.PageCursor(1)
+search:
+	for {
+		select {
+		case <-d.shutdown:
+			logger.Info("Shutting down...")
+			interrupted = true
+			break search
+		default:
+		}
+
+		logger.Info("%s On %s of favorites", t.label(), cursor)
+
+		// Rate limit page requests
+		if err := d.limiter.Wait(context.Background()); err != nil {
+			logger.Error("%s Rate limiter error: %s", t.label(), err)
+			continue
+		}
+
+		posts, err := site.GetPosts(cursor, query)
+		if err != nil {
+			logger.Error("%s Failed after retries: %s...", t.label(), err)
+			continue
+		}
+
+		if len(posts) == 0 {
+			break
+		}
+
+		for _, post := range posts {
+			postID := post.PostID()
+			if seen[postID] {
+				continue
+			}
+			seen[postID] = true
+
+			if entry, ok := known[postID]; ok {
+				if byFavoriteTime && !entry.Interrupted {
+					// Everything further has been seen by previous runs
+					break search
+				}
+				found = append(found, entry)
+				continue
+			}
+			found = append(found, favoriteEntry{PostID: postID})
+
+			if !submitFavorite(post) {
+				interrupted = true
+				break search
+			}
+		}
+
+		cursor = site.NextCursor(cursor, posts, query)
+	}
+	searchOver = !interrupted
+
+	if byFavoriteTime && searchOver {
+		// Older favorites not saved before lie past where the search stopped
+		for _, entry := range manifest.Posts {
+			if entry.done() || seen[entry.PostID] {
+				continue
+			}
+
+			// Rate limit post requests
+			if err := d.limiter.Wait(context.Background()); err != nil {
+				logger.Error("%s Rate limiter error: %s", t.label(), err)
+				continue
+			}
+
+			post, err := site.GetPost(entry.PostID)
+			if err != nil {
+				logger.Warning("%s Failed to get favorite post %d: %s", t.label(), entry.PostID, err)
+				continue
+			}
+
+			if !submitFavorite(post) {
+				interrupted = true
+				break
+			}
+		}
+	}
+
+	jobs.Wait()
+
+	for i := range found {
+		if outcome, ok := outcomes[found[i].PostID]; ok {
+			found[i] = outcome
+		}
+		// Favorites past these are yet to be listed
+		found[i].Interrupted = !searchOver
+	}
+
+	// Otherwise the search was complete and the list is rebuilt without unfavorited posts
+	if byFavoriteTime || !searchOver {
+		// Newer favorites go on top of the known ones
+		listed := make(map[int64]bool, len(found))
+		for _, entry := range found {
+			listed[entry.PostID] = true
+		}
+		for _, entry := range manifest.Posts {
+			if listed[entry.PostID] {
+				continue
+			}
+			if outcome, ok := outcomes[entry.PostID]; ok {
+				entry = outcome
+			}
+			if searchOver {
+				entry.Interrupted = false
+			}
+			found = append(found, entry)
+		}
+	}
+
+	manifest.User = user
+	manifest.FromHost = site.URL.Hostname()
+	manifest.UpdatedAt = time.Now()
+	manifest.Posts = found
+
+	err = manifest.save(manifestPath)
+	if err != nil {
+		logger.Error("%s Failed to save favorites manifest %s: %s", t.label(), manifestPath, err)
+		return
+	}
+	if interrupted {
+		logger.Info("%s Saved favorites of %s found so far (%d total)", t.label(), user, len(manifest.Posts))
+		return
+	}
+	logger.Info("%s Favorites of %s are up to date (%d total)", t.label(), user, len(manifest.Posts))
+}
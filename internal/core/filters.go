@@ -0,0 +1,149 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"Unbewohnte/gobooru-downloader/internal/booru"
+	"Unbewohnte/gobooru-downloader/internal/config"
+	"Unbewohnte/gobooru-downloader/internal/query"
+)
+
+// A condition on a number describing a post
+type postFilter struct {
+	// What the number is, as told in skip reasons
+	name string
+	// Returns the number, reports whether it is known
+	value func(post booru.Post, metadata *booru.Metadata) (float64, bool)
+	// Reports whether the filter applies to post at all
+	appliesTo func(post booru.Post) bool
+	within    query.Range
+}
+
+// Builds filters out of their configuration. Unset filters are left out
+func newPostFilters(filters config.Filters) ([]postFilter, error) {
+	available := []struct {
+		setting string
+		filter  postFilter
+	}{
+		{filters.Score, postFilter{
+			name: "score",
+			value: func(post booru.Post, metadata *booru.Metadata) (float64, bool) {
+				return float64(metadata.Score), true
+			},
+		}},
+		{filters.FavCount, postFilter{
+			name: "favorite count",
+			value: func(post booru.Post, metadata *booru.Metadata) (float64, bool) {
+				if metadata.FavCount == nil {
+					return 0, false
+				}
+				return float64(*metadata.FavCount), true
+			},
+		}},
+		{filters.Width, postFilter{
+			name: "width",
+			value: func(post booru.Post, metadata *booru.Metadata) (float64, bool) {
+				return float64(metadata.Width), metadata.Width > 0
+			},
+		}},
+		{filters.Height, postFilter{
+			name: "height",
+			value: func(post booru.Post, metadata *booru.Metadata) (float64, bool) {
+				return float64(metadata.Height), metadata.Height > 0
+			},
+		}},
+		{filters.AspectRatio, postFilter{
+			name: "aspect ratio",
+			value: func(post booru.Post, metadata *booru.Metadata) (float64, bool) {
+				if metadata.Width <= 0 || metadata.Height <= 0 {
+					return 0, false
+				}
+				return float64(metadata.Width) / float64(metadata.Height), true
+			},
+		}},
+		{filters.Duration, postFilter{
+			name: "duration",
+			value: func(post booru.Post, metadata *booru.Metadata) (float64, bool) {
+				return metadata.Duration, metadata.Duration > 0
+			},
+			// Images have no length to filter by
+			appliesTo: func(post booru.Post) bool {
+				return post.IsVideo()
+			},
+		}},
+	}
+
+	var postFilters []postFilter
+	for _, option := range available {
+		if strings.TrimSpace(option.setting) == "" {
+			continue
+		}
+
+		within, err := query.ParseRange(option.setting)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", option.filter.name, err)
+		}
+		option.filter.within = within
+		postFilters = append(postFilters, option.filter)
+	}
+
+	if filters.MinFileSize != 0 {
+		within, _ := query.ParseRange(fmt.Sprintf(">=%d", filters.MinFileSize))
+		postFilters = append(postFilters, postFilter{
+			name: "size in megabytes",
+			value: func(post booru.Post, metadata *booru.Metadata) (float64, bool) {
+				return float64(post.Size()) / 1024 / 1024, true
+			},
+			// Some boorus tell the size only once media is downloaded, such posts are checked after that
+			appliesTo: func(post booru.Post) bool {
+				return post.Size() != 0
+			},
+			within: within,
+		})
+	}
+
+	return postFilters, nil
+}
+
+// Returns the reason post does not pass filters, blank if it passes them all
+func filterPost(filters []postFilter, post booru.Post) string {
+	if len(filters) == 0 {
+		return ""
+	}
+
+	metadata := post.Metadata()
+	for _, filter := range filters {
+		if filter.appliesTo != nil && !filter.appliesTo(post) {
+			continue
+		}
+
+		value, ok := filter.value(post, metadata)
+		if !ok {
+			return fmt.Sprintf("its %s is unknown", filter.name)
+		}
+		if !filter.within.Contains(value) {
+			return fmt.Sprintf("its %s %.4g is not within %s", filter.name, value, filter.within)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,99 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package core
+
+import (
+	"encoding/json"
+	"time"
+
+	"Unbewohnte/gobooru-downloader/internal/files"
+	"Unbewohnte/gobooru-downloader/internal/logger"
+)
+
+type summaryEntry struct {
+	Name    string  `json:"name,omitempty"`
+	Tags    string  `json:"tags,omitempty"`
+	Output  string  `json:"output,omitempty"`
+	Saved   int     `json:"saved"`
+	SavedGB float64 `json:"saved_gb"`
+	Skipped int     `json:"skipped"`
+	Failed  int     `json:"failed"`
+}
+
+// Outcome of a run, per job and in total
+type runSummary struct {
+	StartedAt time.Time      `json:"started_at"`
+	Elapsed   string         `json:"elapsed"`
+	Jobs      []summaryEntry `json:"jobs,omitempty"`
+	Total     summaryEntry   `json:"total"`
+}
+
+// Sums up what tasks of the run did and logs it. Tasks are listed one by one only if named
+func (d *Downloader) summarize(tasks []*task) runSummary {
+	summary := runSummary{
+		StartedAt: d.startTime,
+		Elapsed:   time.Since(d.startTime).Round(time.Second).String(),
+	}
+
+	var totalBytes uint64
+	for _, t := range tasks {
+		stats := t.getStats()
+		totalBytes += stats.SavedBytes
+		summary.Total.Saved += stats.Saved
+		summary.Total.Skipped += stats.Skipped
+		summary.Total.Failed += stats.Failed
+
+		if t.name == "" {
+			continue
+		}
+
+		entry := summaryEntry{
+			Name:    t.name,
+			Tags:    t.config.Tags,
+			Output:  t.config.OutputDir,
+			Saved:   stats.Saved,
+			SavedGB: gigabytes(stats.SavedBytes),
+			Skipped: stats.Skipped,
+			Failed:  stats.Failed,
+		}
+		summary.Jobs = append(summary.Jobs, entry)
+
+		logger.Info(
+			"[Summary] %s (%q): saved %d (%.02fGB), skipped %d, failed %d",
+			entry.Name, entry.Tags, entry.Saved, entry.SavedGB, entry.Skipped, entry.Failed,
+		)
+	}
+	summary.Total.SavedGB = gigabytes(totalBytes)
+
+	logger.Info(
+		"[Summary] Total: saved %d (%.02fGB), skipped %d, failed %d in %s",
+		summary.Total.Saved, summary.Total.SavedGB, summary.Total.Skipped, summary.Total.Failed, summary.Elapsed,
+	)
+
+	return summary
+}
+
+func (summary runSummary) save(path string) error {
+	contents, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return files.WriteAtomic(path, contents)
+}
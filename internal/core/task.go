@@ -0,0 +1,204 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package core
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"Unbewohnte/gobooru-downloader/internal/booru"
+	"Unbewohnte/gobooru-downloader/internal/config"
+	"Unbewohnte/gobooru-downloader/internal/logger"
+	"Unbewohnte/gobooru-downloader/internal/query"
+)
+
+// What to download, where to and which posts to skip. A run has a single task
+// made of the configuration or a task per job of a job file
+type task struct {
+	// Blank for the task of the configuration
+	name   string
+	config *config.Config
+	// Nil if tags are given to the site as is
+	tagQuery  *query.Query
+	quality   booru.QualityPolicy
+	blacklist *query.Blacklist
+	// Ratings of posts to save, any if empty
+	ratings map[booru.Rating]bool
+	filters []postFilter
+	bounds  searchBounds
+	// Amount of posts to save, 0 for no cap
+	maxPosts uint
+
+	// Posts submitted and families looked up by the task, by postKey
+	submitted map[string]bool
+	families  map[string]bool
+
+	mutex sync.Mutex
+	stats taskStats
+	// Posts being saved and their sizes, counted against the limits
+	pendingCount int
+	pendingBytes uint64
+}
+
+type taskStats struct {
+	Saved      int
+	SavedBytes uint64
+	Skipped    int
+	Failed     int
+}
+
+func newTask(site *booru.Site, cfg *config.Config, name string) (*task, error) {
+	t := &task{
+		name:      name,
+		config:    cfg,
+		ratings:   make(map[booru.Rating]bool),
+		submitted: make(map[string]bool),
+		families:  make(map[string]bool),
+	}
+
+	var err error
+	t.quality, err = booru.ParseQualityPolicy(cfg.Quality)
+	if err != nil {
+		return nil, err
+	}
+
+	ratings, err := booru.ParseRatings(cfg.Ratings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ratings: %w", err)
+	}
+	for _, rating := range ratings {
+		t.ratings[rating] = true
+	}
+
+	filters, err := cfg.PostFilters()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load filters %w", err)
+	}
+	t.filters, err = newPostFilters(filters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filters: %w", err)
+	}
+
+	t.bounds, err = newSearchBounds(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bounds: %w", err)
+	}
+
+	if strings.TrimSpace(cfg.Blacklist) != "" {
+		t.blacklist, err = query.LoadBlacklist(cfg.Blacklist)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load blacklist %s: %w", cfg.Blacklist, err)
+		}
+		logger.Info("%s Loaded %d blacklist rules", t.label(), t.blacklist.Len())
+	}
+
+	// Boorus with their own search syntax are given tags as is, as are tags not making a query
+	if _, ok := site.TagLimit(); ok {
+		t.tagQuery, err = query.Parse(cfg.Tags)
+		if err != nil {
+			logger.Warning("%s Searching for %q as is, it is not a query: %s", t.label(), cfg.Tags, err)
+			t.tagQuery = nil
+		}
+	}
+
+	err = os.MkdirAll(cfg.OutputDir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Returns the tag of log messages about the task
+func (t *task) label() string {
+	if t.name == "" {
+		return "[Main]"
+	}
+	return fmt.Sprintf("[Job %s]", t.name)
+}
+
+func gigabytes(bytes uint64) float64 {
+	return float64(bytes) / 1024.0 / 1024.0 / 1024.0
+}
+
+// Reports whether saving count more posts of given amount of bytes would go over the limits
+func (t *task) over(count int, bytes uint64) bool {
+	if t.maxPosts != 0 && count >= int(t.maxPosts) {
+		return true
+	}
+	if t.config.DownloadLimitGb != 0 && gigabytes(bytes) >= t.config.DownloadLimitGb {
+		return true
+	}
+	return false
+}
+
+// Reports whether the task has saved as much as it is allowed to
+func (t *task) limitReached() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.over(t.stats.Saved, t.stats.SavedBytes)
+}
+
+// Counts a post of size (0 if unknown) as being saved, unless
+// posts saved and being saved already reach the limits
+func (t *task) reserve(size uint64) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.over(t.stats.Saved+t.pendingCount, t.stats.SavedBytes+t.pendingBytes) {
+		return false
+	}
+
+	t.pendingCount++
+	t.pendingBytes += size
+	return true
+}
+
+// Counts the result of a job, releasing the post reserved with size if reserved is set
+func (t *task) record(result Result, reserved bool, size uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if reserved {
+		t.pendingCount--
+		t.pendingBytes -= size
+	}
+
+	switch {
+	case result.Success:
+		t.stats.Saved++
+		if result.Metadata != nil {
+			t.stats.SavedBytes += result.Metadata.Size
+		}
+	case result.Skip:
+		t.stats.Skipped++
+	default:
+		t.stats.Failed++
+	}
+}
+
+func (t *task) getStats() taskStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.stats
+}
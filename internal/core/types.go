@@ -18,14 +18,33 @@
 
 package core
 
-import "Unbewohnte/gobooru-downloader/internal/booru"
+import (
+	"sync"
+
+	"Unbewohnte/gobooru-downloader/internal/booru"
+)
 
 type Job struct {
+	// Site the post comes from
+	Site *booru.Site
 	Post booru.Post
+	// Directory to save to, output directory if blank
+	Directory string
+	// 1-based position of the post in its collection, 0 if not a part of one
+	Position int
+	// Called with the name of media file once it is saved
+	onSaved func(fileName string)
+	// Called with the reason the post is filtered out, if it is
+	onFiltered func(reason string)
+	// Marked done once the job is finished, whatever the result
+	done *sync.WaitGroup
+	// Task the job is a part of
+	task *task
 }
 
-func NewJob(post booru.Post) Job {
+func NewJob(site *booru.Site, post booru.Post) Job {
 	return Job{
+		Site: site,
 		Post: post,
 	}
 }
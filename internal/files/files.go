@@ -0,0 +1,33 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package files
+
+import "os"
+
+// Writes contents to a temporary file next to path and moves it over path once written,
+// so an interrupted run does not leave a half written file behind
+func WriteAtomic(path string, contents []byte) error {
+	temporaryPath := path + ".tmp"
+	err := os.WriteFile(temporaryPath, contents, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temporaryPath, path)
+}
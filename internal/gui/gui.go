@@ -23,8 +23,10 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
+	"Unbewohnte/gobooru-downloader/internal/booru"
 	"Unbewohnte/gobooru-downloader/internal/config"
 	"Unbewohnte/gobooru-downloader/internal/core"
 	"Unbewohnte/gobooru-downloader/internal/logger"
@@ -200,6 +202,21 @@ func (g *GUI) showSettings() {
 	booruURLEntry := widget.NewEntry()
 	booruURLEntry.SetText(g.config.BooruURL.String())
 
+	engineSelect := widget.NewSelect(append([]string{"auto"}, booru.ProviderNames()...), nil)
+	engineSelect.SetSelected("auto")
+	if g.config.Engine != "" {
+		engineSelect.SetSelected(g.config.Engine)
+	}
+
+	loginEntry := widget.NewEntry()
+	loginEntry.SetText(g.config.Credentials.Login)
+
+	apiKeyEntry := widget.NewPasswordEntry()
+	apiKeyEntry.SetText(g.config.Credentials.APIKey)
+
+	userIDEntry := widget.NewEntry()
+	userIDEntry.SetText(g.config.Credentials.UserID)
+
 	workersEntry := widget.NewEntry()
 	workersEntry.SetText(strconv.Itoa(int(g.config.WorkerCount)))
 
@@ -212,6 +229,46 @@ func (g *GUI) showSettings() {
 	tagsEntry := widget.NewEntry()
 	tagsEntry.SetText(g.config.Tags)
 
+	postsEntry := widget.NewEntry()
+	postsEntry.SetPlaceHolder("IDs or post URLs (blank to search by tags)")
+	postsEntry.SetText(g.config.Posts)
+
+	poolEntry := widget.NewEntry()
+	poolEntry.SetPlaceHolder("Pool ID (danbooru)")
+	if g.config.Pool != 0 {
+		poolEntry.SetText(strconv.FormatInt(g.config.Pool, 10))
+	}
+
+	favoriteGroupEntry := widget.NewEntry()
+	favoriteGroupEntry.SetPlaceHolder("Favorite group ID (danbooru)")
+	if g.config.FavoriteGroup != 0 {
+		favoriteGroupEntry.SetText(strconv.FormatInt(g.config.FavoriteGroup, 10))
+	}
+
+	favoritesEntry := widget.NewEntry()
+	favoritesEntry.SetPlaceHolder("User name (user ID for gelbooru)")
+	favoritesEntry.SetText(g.config.Favorites)
+
+	sinceEntry := widget.NewEntry()
+	sinceEntry.SetPlaceHolder("YYYY-MM-DD (blank for no bound)")
+	sinceEntry.SetText(g.config.Since)
+
+	untilEntry := widget.NewEntry()
+	untilEntry.SetPlaceHolder("YYYY-MM-DD, inclusive (blank for no bound)")
+	untilEntry.SetText(g.config.Until)
+
+	minIDEntry := widget.NewEntry()
+	minIDEntry.SetPlaceHolder("Lowest post ID (blank for no bound)")
+	if g.config.MinID != 0 {
+		minIDEntry.SetText(strconv.FormatInt(g.config.MinID, 10))
+	}
+
+	maxIDEntry := widget.NewEntry()
+	maxIDEntry.SetPlaceHolder("Highest post ID (blank for no bound)")
+	if g.config.MaxID != 0 {
+		maxIDEntry.SetText(strconv.FormatInt(g.config.MaxID, 10))
+	}
+
 	fromPageEntry := widget.NewEntry()
 	fromPageEntry.SetText(strconv.Itoa(int(g.config.FromPage)))
 
@@ -221,27 +278,113 @@ func (g *GUI) showSettings() {
 	downloadLimitGBEntry := widget.NewEntry()
 	downloadLimitGBEntry.SetText(strconv.Itoa(int(g.config.DownloadLimitGb)))
 
+	qualityEntry := widget.NewEntry()
+	qualityEntry.SetPlaceHolder("original, sample, 720, max-width=N, smallest-above=WxH")
+	qualityEntry.SetText(g.config.Quality)
+
+	minFileSizeEntry := widget.NewEntry()
+	minFileSizeEntry.SetText(strconv.Itoa(int(g.config.Filters.MinFileSize)))
+
+	// Numeric filters are ranges: N, >N, >=N, <N, <=N, N..M, N.. or ..M
+	newRangeEntry := func(placeHolder string, value string) *widget.Entry {
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder(placeHolder)
+		entry.SetText(value)
+		return entry
+	}
+	scoreEntry := newRangeEntry("e.g. >=10 (blank for any)", g.config.Filters.Score)
+	favCountEntry := newRangeEntry("e.g. >=100 (blank for any)", g.config.Filters.FavCount)
+	widthEntry := newRangeEntry("e.g. >=1920 (blank for any)", g.config.Filters.Width)
+	heightEntry := newRangeEntry("e.g. 720..2160 (blank for any)", g.config.Filters.Height)
+	aspectRatioEntry := newRangeEntry("e.g. 1.7..1.8 (blank for any)", g.config.Filters.AspectRatio)
+	durationEntry := newRangeEntry("Seconds, e.g. ..30 (blank for any)", g.config.Filters.Duration)
+
+	filtersFileEntry := widget.NewEntry()
+	filtersFileEntry.SetPlaceHolder("Path to a JSON filters file")
+	filtersFileEntry.SetText(g.config.FiltersFile)
+
+	ratingsEntry := widget.NewEntry()
+	ratingsEntry.SetPlaceHolder("general, sensitive, questionable, explicit (blank for any)")
+	ratingsEntry.SetText(g.config.Ratings)
+
+	blacklistEntry := widget.NewEntry()
+	blacklistEntry.SetPlaceHolder("Path to a blacklist file")
+	blacklistEntry.SetText(g.config.Blacklist)
+
+	jobsFileEntry := widget.NewEntry()
+	jobsFileEntry.SetPlaceHolder("Path to a JSON job file (blank for a single search)")
+	jobsFileEntry.SetText(g.config.JobsFile)
+
 	maxRetriesEntry := widget.NewEntry()
 	maxRetriesEntry.SetText(strconv.Itoa(int(g.config.MaxRetries)))
 
 	noMetadataCheck := widget.NewCheck("No metadata", func(b bool) { g.config.NoMetadata = b })
 	noMetadataCheck.Checked = g.config.NoMetadata
 
+	notesHTMLCheck := widget.NewCheck("Notes HTML page", func(b bool) { g.config.NotesHTML = b })
+	notesHTMLCheck.Checked = g.config.NotesHTML
+
+	commentsCheck := widget.NewCheck("Save comments", func(b bool) { g.config.Comments = b })
+	commentsCheck.Checked = g.config.Comments
+
+	commentaryCheck := widget.NewCheck("Artist commentary", func(b bool) { g.config.Commentary = b })
+	commentaryCheck.Checked = g.config.Commentary
+
+	followRelationsCheck := widget.NewCheck("Follow relations", func(b bool) { g.config.FollowRelations = b })
+	followRelationsCheck.Checked = g.config.FollowRelations
+
 	form := &widget.Form{
 		Items: []*widget.FormItem{
 			{Text: "Booru URL", Widget: booruURLEntry},
+			{Text: "Engine", Widget: engineSelect},
+			{Text: "Login", Widget: loginEntry},
+			{Text: "API key", Widget: apiKeyEntry},
+			{Text: "User ID", Widget: userIDEntry},
 			{Text: "Worker Count", Widget: workersEntry},
 			{Text: "Output Directory", Widget: outputDirEntry},
 			{Text: "Max Retries", Widget: maxRetriesEntry},
 			{Text: "Proxy connection string", Widget: proxyEntry},
 			{Text: "Tags", Widget: tagsEntry},
+			{Text: "Posts", Widget: postsEntry},
+			{Text: "Pool", Widget: poolEntry},
+			{Text: "Favorite group", Widget: favoriteGroupEntry},
+			{Text: "Favorites of", Widget: favoritesEntry},
 			{Text: "From page", Widget: fromPageEntry},
+			{Text: "Uploaded since", Widget: sinceEntry},
+			{Text: "Uploaded until", Widget: untilEntry},
+			{Text: "Min post ID", Widget: minIDEntry},
+			{Text: "Max post ID", Widget: maxIDEntry},
 			{Text: "Max file size (MB)", Widget: maxFileSizeEntry},
 			{Text: "Download limit (GB)", Widget: downloadLimitGBEntry},
+			{Text: "Quality", Widget: qualityEntry},
+			{Text: "Min file size (MB)", Widget: minFileSizeEntry},
+			{Text: "Score", Widget: scoreEntry},
+			{Text: "Favorites count", Widget: favCountEntry},
+			{Text: "Width", Widget: widthEntry},
+			{Text: "Height", Widget: heightEntry},
+			{Text: "Aspect ratio", Widget: aspectRatioEntry},
+			{Text: "Video duration", Widget: durationEntry},
+			{Text: "Filters file", Widget: filtersFileEntry},
+			{Text: "Ratings", Widget: ratingsEntry},
+			{Text: "Blacklist", Widget: blacklistEntry},
+			{Text: "Jobs file", Widget: jobsFileEntry},
 			{Text: "No metadata", Widget: noMetadataCheck},
+			{Text: "Notes HTML page", Widget: notesHTMLCheck},
+			{Text: "Comments", Widget: commentsCheck},
+			{Text: "Artist commentary", Widget: commentaryCheck},
+			{Text: "Parents and children", Widget: followRelationsCheck},
 		},
 		OnSubmit: func() {
 			// Update config
+			g.config.Engine = engineSelect.Selected
+			if g.config.Engine == "auto" {
+				g.config.Engine = ""
+			}
+
+			g.config.Credentials.Login = loginEntry.Text
+			g.config.Credentials.APIKey = apiKeyEntry.Text
+			g.config.Credentials.UserID = userIDEntry.Text
+
 			workerCount, err := strconv.Atoi(workersEntry.Text)
 			if err == nil {
 				g.config.WorkerCount = uint(workerCount)
@@ -257,6 +400,16 @@ func (g *GUI) showSettings() {
 			g.config.ProxyString = proxyEntry.Text
 
 			g.config.Tags = tagsEntry.Text
+			g.config.Posts = postsEntry.Text
+
+			g.config.Pool, _ = strconv.ParseInt(poolEntry.Text, 10, 64)
+			g.config.FavoriteGroup, _ = strconv.ParseInt(favoriteGroupEntry.Text, 10, 64)
+			g.config.Favorites = strings.TrimSpace(favoritesEntry.Text)
+
+			g.config.Since = strings.TrimSpace(sinceEntry.Text)
+			g.config.Until = strings.TrimSpace(untilEntry.Text)
+			g.config.MinID, _ = strconv.ParseInt(minIDEntry.Text, 10, 64)
+			g.config.MaxID, _ = strconv.ParseInt(maxIDEntry.Text, 10, 64)
 
 			fromPage, err := strconv.Atoi(fromPageEntry.Text)
 			if err == nil {
@@ -274,6 +427,26 @@ func (g *GUI) showSettings() {
 			}
 
 			g.config.NoMetadata = noMetadataCheck.Checked
+			g.config.Quality = qualityEntry.Text
+			minFileSizeMB, err := strconv.ParseFloat(minFileSizeEntry.Text, 64)
+			if err == nil {
+				g.config.Filters.MinFileSize = uint(math.Floor(minFileSizeMB))
+			}
+
+			g.config.Filters.Score = scoreEntry.Text
+			g.config.Filters.FavCount = favCountEntry.Text
+			g.config.Filters.Width = widthEntry.Text
+			g.config.Filters.Height = heightEntry.Text
+			g.config.Filters.AspectRatio = aspectRatioEntry.Text
+			g.config.Filters.Duration = durationEntry.Text
+			g.config.FiltersFile = strings.TrimSpace(filtersFileEntry.Text)
+			g.config.Ratings = ratingsEntry.Text
+			g.config.Blacklist = strings.TrimSpace(blacklistEntry.Text)
+			g.config.JobsFile = strings.TrimSpace(jobsFileEntry.Text)
+			g.config.NotesHTML = notesHTMLCheck.Checked
+			g.config.Comments = commentsCheck.Checked
+			g.config.Commentary = commentaryCheck.Checked
+			g.config.FollowRelations = followRelationsCheck.Checked
 
 			settingsWindow.Close()
 		},
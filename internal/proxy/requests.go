@@ -19,23 +19,78 @@
 package proxy
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
 var MAXRETRIES uint = 5
 
+// User-Agent header sent with every request. Some boorus (e621) reject generic user agents
+var USERAGENT string = "gobooru-downloader (https://github.com/Unbewohnte/gobooru-downloader)"
+
+// Query parameters carrying credentials, their values never leave this package in errors
+var sensitiveParameters = []string{"api_key", "key", "login", "user_id", "password_hash"}
+
+// Returns rawURL with credentials in its query and user info replaced
+func RedactURL(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	query := parsedURL.Query()
+	for _, parameter := range sensitiveParameters {
+		if query.Has(parameter) {
+			query.Set(parameter, "REDACTED")
+		}
+	}
+	parsedURL.RawQuery = query.Encode()
+
+	return parsedURL.Redacted()
+}
+
+// Strips credentials from URLs mentioned in err
+func redactError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = RedactURL(urlErr.URL)
+	}
+
+	return err
+}
+
+// Returned when a booru answers with a status code other than 200
+type StatusError struct {
+	StatusCode int
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("status code %d", err.StatusCode)
+}
+
 // Perform a GET request to the specified URL with retries.
 // It retries the request up to `retries` times if it fails due to transient errors.
 func DoGETRetry(client *http.Client, url string) (*http.Response, error) {
+	return DoGETRetryWithHeaders(client, url, nil)
+}
+
+// Same as DoGETRetry, but sets additional headers on each request
+func DoGETRetryWithHeaders(client *http.Client, url string, headers map[string]string) (*http.Response, error) {
 	var response *http.Response
 	var err error
 
+	requestHeaders := map[string]string{"User-Agent": USERAGENT}
+	for key, value := range headers {
+		requestHeaders[key] = value
+	}
+
 	for attempt := uint(0); attempt <= MAXRETRIES; attempt++ {
 		// Perform the GET request
-		response, err = client.Get(url)
+		response, err = DoRequest(client, http.MethodGet, url, requestHeaders)
 		if err == nil && response.StatusCode < 500 {
 			return response, nil
 		}
@@ -48,22 +103,27 @@ func DoGETRetry(client *http.Client, url string) (*http.Response, error) {
 
 	// Return the last error or response after all retries
 	if err != nil {
-		return nil, err
+		return nil, redactError(err)
 	}
 
-	return nil, fmt.Errorf("status code: %d", response.StatusCode)
+	return nil, &StatusError{StatusCode: response.StatusCode}
 }
 
 // Downloads a content from the given URL and returns its content as a byte slice
 func GetContents(client *http.Client, contentURL string) ([]byte, error) {
-	response, err := DoGETRetry(client, contentURL)
+	return GetContentsWithHeaders(client, contentURL, nil)
+}
+
+// Same as GetContents, but sets additional headers on the request
+func GetContentsWithHeaders(client *http.Client, contentURL string, headers map[string]string) ([]byte, error) {
+	response, err := DoGETRetryWithHeaders(client, contentURL, headers)
 	if err != nil {
 		return nil, err
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status code %d", response.StatusCode)
+		return nil, &StatusError{StatusCode: response.StatusCode}
 	}
 
 	// Read the content into a byte slice
@@ -0,0 +1,104 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package query
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/booru"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Posts to be skipped, described with danbooru's blacklist syntax: a rule per line,
+// matching posts having all of its tags. Tags may be negated with "-", metatags
+// rating:, score:, favcount:, id:, width:, height: and md5: are matched against metadata
+type Blacklist struct {
+	rules []blacklistRule
+}
+
+type blacklistRule struct {
+	text  string
+	query *Query
+}
+
+// Parses blacklist rules, one per line. Blank lines and lines starting with # are ignored
+func ParseBlacklist(text string) (*Blacklist, error) {
+	blacklist := &Blacklist{}
+	for number, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		query, err := parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number+1, err)
+		}
+
+		unsupported := findTerm(query.root, func(term *termNode) bool {
+			return term.metatag && term.condition == nil
+		})
+		if unsupported != "" {
+			_, err := metatagCondition(unsupported)
+			return nil, fmt.Errorf("line %d: %w", number+1, err)
+		}
+
+		blacklist.rules = append(blacklist.rules, blacklistRule{
+			text:  line,
+			query: query,
+		})
+	}
+
+	return blacklist, nil
+}
+
+// Reads blacklist rules from file at path
+func LoadBlacklist(path string) (*Blacklist, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseBlacklist(string(contents))
+}
+
+// Returns the first rule matching post. Reports whether there is one
+func (blacklist *Blacklist) Match(post booru.Post) (string, bool) {
+	if blacklist == nil || len(blacklist.rules) == 0 {
+		return "", false
+	}
+
+	subject := newSubject(booru.AllTags(post), post.Metadata())
+	for _, rule := range blacklist.rules {
+		if rule.query.root.matches(subject) {
+			return rule.text, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns amount of rules
+func (blacklist *Blacklist) Len() int {
+	if blacklist == nil {
+		return 0
+	}
+
+	return len(blacklist.rules)
+}
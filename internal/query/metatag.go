@@ -0,0 +1,96 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package query
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/booru"
+	"fmt"
+	"strings"
+)
+
+// Returns a condition matching metadata against metatag ("name:value"),
+// or an error if the metatag can not be matched locally
+func metatagCondition(metatag string) (func(metadata *booru.Metadata) bool, error) {
+	name, value, _ := strings.Cut(metatag, ":")
+
+	switch name {
+	case "rating":
+		ratings, err := booru.ParseRatings(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(ratings) == 0 {
+			return nil, fmt.Errorf("no ratings in %q", metatag)
+		}
+
+		return func(metadata *booru.Metadata) bool {
+			for _, rating := range ratings {
+				if metadata.Rating == rating {
+					return true
+				}
+			}
+			return false
+		}, nil
+
+	case "md5":
+		return func(metadata *booru.Metadata) bool {
+			return strings.EqualFold(metadata.MD5, value)
+		}, nil
+	}
+
+	// Numeric metatags
+	var field func(metadata *booru.Metadata) (int64, bool)
+	switch name {
+	case "score":
+		field = func(metadata *booru.Metadata) (int64, bool) {
+			return int64(metadata.Score), true
+		}
+	case "favcount":
+		field = func(metadata *booru.Metadata) (int64, bool) {
+			if metadata.FavCount == nil {
+				return 0, false
+			}
+			return int64(*metadata.FavCount), true
+		}
+	case "id":
+		field = func(metadata *booru.Metadata) (int64, bool) {
+			return metadata.ID, true
+		}
+	case "width":
+		field = func(metadata *booru.Metadata) (int64, bool) {
+			return int64(metadata.Width), true
+		}
+	case "height":
+		field = func(metadata *booru.Metadata) (int64, bool) {
+			return int64(metadata.Height), true
+		}
+	default:
+		return nil, fmt.Errorf("metatag %q can not be matched locally", name)
+	}
+
+	numberRange, err := ParseRange(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", metatag, err)
+	}
+
+	return func(metadata *booru.Metadata) bool {
+		number, ok := field(metadata)
+		return ok && numberRange.Contains(float64(number))
+	}, nil
+}
@@ -0,0 +1,720 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package query
+
+import (
+	"Unbewohnte/gobooru-downloader/internal/booru"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// A parsed tag query: tags combined with AND (juxtaposition), OR (or, |, ~tag),
+// "-" negation and parentheses. Tags may contain * wildcards. Gelbooru's {tag1 ~ tag2}
+// is understood as well
+type Query struct {
+	root node
+}
+
+type node interface {
+	matches(post *subject) bool
+	String() string
+}
+
+// Set of lowercase tags of a post
+type tagSet map[string]bool
+
+// A post being matched: its tags and, if metatags are matched as well, its metadata
+type subject struct {
+	tags     tagSet
+	metadata *booru.Metadata
+}
+
+func newSubject(tags []string, metadata *booru.Metadata) *subject {
+	set := make(tagSet, len(tags))
+	for _, tag := range tags {
+		set[strings.ToLower(tag)] = true
+	}
+
+	return &subject{
+		tags:     set,
+		metadata: metadata,
+	}
+}
+
+type termNode struct {
+	tag      string
+	metatag  bool
+	wildcard bool
+	// Matches metadata against the metatag, nil if the metatag can not be matched locally
+	condition func(metadata *booru.Metadata) bool
+}
+
+type notNode struct {
+	operand node
+}
+
+type andNode []node
+
+type orNode []node
+
+// Metatags are passed to the booru and can not be matched locally
+var metatags = map[string]bool{
+	"age": true, "approver": true, "child": true, "comm": true, "commenter": true,
+	"date": true, "description": true, "downvote": true, "duration": true, "fav": true,
+	"favcount": true, "favgroup": true, "faved_by": true, "filesize": true, "filetype": true,
+	"has": true, "height": true, "id": true, "inpool": true, "is": true,
+	"ischild": true, "isparent": true, "limit": true, "md5": true, "mpixels": true,
+	"note": true, "noter": true, "order": true, "ordfav": true, "ordfavgroup": true,
+	"ordpool": true, "parent": true, "pixiv": true, "pixiv_id": true, "pool": true,
+	"rating": true, "ratio": true, "safety": true, "score": true, "search": true,
+	"sort": true, "source": true, "status": true, "tagcount": true, "type": true,
+	"upvote": true, "user": true, "vote": true, "width": true,
+}
+
+func newTerm(tag string) *termNode {
+	name, value, found := strings.Cut(tag, ":")
+	name = strings.ToLower(name)
+
+	term := &termNode{
+		tag:     strings.ToLower(tag),
+		metatag: found && metatags[name],
+	}
+	if term.metatag {
+		// Values may be case sensitive, such as source URLs or user names
+		term.tag = name + ":" + value
+		term.condition, _ = metatagCondition(term.tag)
+	}
+	term.wildcard = strings.Contains(term.tag, "*")
+
+	return term
+}
+
+func (term *termNode) matches(post *subject) bool {
+	if term.metatag {
+		return term.condition != nil && post.metadata != nil && term.condition(post.metadata)
+	}
+
+	if !term.wildcard {
+		return post.tags[term.tag]
+	}
+
+	for tag := range post.tags {
+		if matchWildcard(term.tag, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (term *termNode) String() string {
+	return term.tag
+}
+
+func (not *notNode) matches(post *subject) bool {
+	return !not.operand.matches(post)
+}
+
+func (not *notNode) String() string {
+	return "-" + not.operand.String()
+}
+
+func (and andNode) matches(post *subject) bool {
+	for _, operand := range and {
+		if !operand.matches(post) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (and andNode) String() string {
+	operands := make([]string, len(and))
+	for i, operand := range and {
+		operands[i] = operand.String()
+	}
+
+	return "( " + strings.Join(operands, " ") + " )"
+}
+
+func (or orNode) matches(post *subject) bool {
+	for _, operand := range or {
+		if operand.matches(post) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (or orNode) String() string {
+	operands := make([]string, len(or))
+	for i, operand := range or {
+		operands[i] = operand.String()
+	}
+
+	return "( " + strings.Join(operands, " or ") + " )"
+}
+
+// Reports whether tag matches pattern, where * stands for any amount of characters
+func matchWildcard(pattern string, tag string) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(tag, parts[0]) {
+		return false
+	}
+	tag = tag[len(parts[0]):]
+
+	last := len(parts) - 1
+	for _, part := range parts[1:last] {
+		index := strings.Index(tag, part)
+		if index < 0 {
+			return false
+		}
+		tag = tag[index+len(part):]
+	}
+
+	return strings.HasSuffix(tag, parts[last])
+}
+
+// A word of an expression with parentheses at its edges split off
+type word struct {
+	// "(" and "-(" at the start, outermost first
+	opening []string
+	tag     string
+	// Amount of ")" at the end
+	closing int
+}
+
+// Splits expression into words, parentheses and negations. Parentheses at the edges of words
+// only group words if they stand alone or are matched by others across words, so that tags
+// like "name_(series)" or ":)" stay whole
+func tokenize(expression string) []string {
+	// Gelbooru groups alternatives with braces
+	expression = strings.NewReplacer("{", "(", "}", ")").Replace(expression)
+
+	var words []word
+	for _, field := range strings.Fields(expression) {
+		var w word
+		// Parentheses balanced within a word are a part of the tag
+		if strings.Count(field, "(") != strings.Count(field, ")") {
+			for {
+				if strings.HasPrefix(field, "(") {
+					w.opening = append(w.opening, "(")
+					field = field[1:]
+				} else if strings.HasPrefix(field, "-(") {
+					w.opening = append(w.opening, "-(")
+					field = field[2:]
+				} else {
+					break
+				}
+			}
+
+			for strings.HasSuffix(field, ")") && strings.Count(field, ")") > strings.Count(field, "(") {
+				field = field[:len(field)-1]
+				w.closing++
+			}
+		}
+		w.tag = field
+		words = append(words, w)
+	}
+
+	// Match opening parentheses with closing ones following them
+	type position struct {
+		word  int
+		index int
+	}
+	var (
+		unclosed        []position
+		matchedOpening  = make([][]bool, len(words))
+		matchedClosings = make([]int, len(words))
+	)
+	for i, w := range words {
+		matchedOpening[i] = make([]bool, len(w.opening))
+		for j := range w.opening {
+			unclosed = append(unclosed, position{word: i, index: j})
+		}
+
+		for matchedClosings[i] < w.closing && len(unclosed) != 0 {
+			opening := unclosed[len(unclosed)-1]
+			unclosed = unclosed[:len(unclosed)-1]
+			matchedOpening[opening.word][opening.index] = true
+			matchedClosings[i]++
+		}
+	}
+
+	// Unmatched parentheses are put back into their words
+	var tokens []string
+	for i, w := range words {
+		tag := w.tag
+		for j := len(w.opening) - 1; j >= 0; j-- {
+			if !matchedOpening[i][j] {
+				tag = w.opening[j] + tag
+			}
+		}
+		tag += strings.Repeat(")", w.closing-matchedClosings[i])
+
+		for j, opening := range w.opening {
+			if !matchedOpening[i][j] {
+				continue
+			}
+			if opening == "-(" {
+				tokens = append(tokens, "-")
+			}
+			tokens = append(tokens, "(")
+		}
+		if tag != "" {
+			tokens = append(tokens, tag)
+		}
+		for j := 0; j < matchedClosings[i]; j++ {
+			tokens = append(tokens, ")")
+		}
+	}
+
+	return tokens
+}
+
+type parser struct {
+	tokens []string
+	next   int
+}
+
+func (p *parser) peek() string {
+	if p.next >= len(p.tokens) {
+		return ""
+	}
+
+	return p.tokens[p.next]
+}
+
+func isOr(token string) bool {
+	return strings.EqualFold(token, "or") || token == "|" || token == "||" || token == "~"
+}
+
+func isAnd(token string) bool {
+	return strings.EqualFold(token, "and") || token == "&" || token == "&&"
+}
+
+func (p *parser) parseOr() (node, error) {
+	var operands orNode
+	for {
+		operand, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+
+		if !isOr(p.peek()) {
+			break
+		}
+		p.next++
+	}
+
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+
+	return operands, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	var operands andNode
+	// Danbooru's ~tag1 ~tag2 matches either of the tags
+	var alternatives orNode
+	for {
+		token := p.peek()
+		if token == "" || token == ")" || isOr(token) {
+			break
+		}
+		if isAnd(token) {
+			p.next++
+			continue
+		}
+
+		if strings.HasPrefix(token, "~") && len(token) > 1 {
+			p.next++
+			alternatives = append(alternatives, newTerm(token[1:]))
+			continue
+		}
+
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+
+	switch len(alternatives) {
+	case 0:
+	case 1:
+		operands = append(operands, alternatives[0])
+	default:
+		operands = append(operands, alternatives)
+	}
+
+	switch len(operands) {
+	case 0:
+		if p.peek() == "" {
+			return nil, fmt.Errorf("unexpected end of query")
+		}
+		return nil, fmt.Errorf("unexpected %q", p.peek())
+	case 1:
+		return operands[0], nil
+	default:
+		return operands, nil
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	token := p.peek()
+	p.next++
+
+	switch {
+	case token == "-":
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+
+	case token == "(":
+		operand, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.next++
+		return operand, nil
+
+	case strings.HasPrefix(token, "-") && len(token) > 1:
+		return &notNode{operand: newTerm(token[1:])}, nil
+
+	default:
+		return newTerm(token), nil
+	}
+}
+
+func parse(expression string) (*Query, error) {
+	tokens := tokenize(expression)
+	if len(tokens) == 0 {
+		return &Query{}, nil
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.next < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.peek())
+	}
+
+	return &Query{root: root}, nil
+}
+
+// Parses a tag query. Metatags (e.g. rating:g, order:score) are left to the booru,
+// so they may only be used at top level, not inside OR groups or negated groups
+func Parse(expression string) (*Query, error) {
+	query, err := parse(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, conjunct := range query.conjuncts() {
+		if _, ok := serverTerm(conjunct); ok {
+			continue
+		}
+		if metatag := findMetatag(conjunct); metatag != "" {
+			return nil, fmt.Errorf("metatag %q can not be used inside a group", metatag)
+		}
+	}
+
+	return query, nil
+}
+
+// Returns the first metatag found in n
+func findMetatag(n node) string {
+	return findTerm(n, func(term *termNode) bool {
+		return term.metatag
+	})
+}
+
+// Returns the first term in n satisfying condition, blank if none does
+func findTerm(n node, condition func(term *termNode) bool) string {
+	switch n := n.(type) {
+	case *termNode:
+		if condition(n) {
+			return n.tag
+		}
+	case *notNode:
+		return findTerm(n.operand, condition)
+	case andNode:
+		for _, operand := range n {
+			if tag := findTerm(operand, condition); tag != "" {
+				return tag
+			}
+		}
+	case orNode:
+		for _, operand := range n {
+			if tag := findTerm(operand, condition); tag != "" {
+				return tag
+			}
+		}
+	}
+
+	return ""
+}
+
+// Returns operands of the top level AND
+func (q *Query) conjuncts() []node {
+	switch root := q.root.(type) {
+	case nil:
+		return nil
+	case andNode:
+		return root
+	default:
+		return []node{root}
+	}
+}
+
+// Returns the term of n if n is a term that a booru can search for: a tag,
+// a negated tag or a metatag. Reports whether n is one
+func serverTerm(n node) (*termNode, bool) {
+	switch n := n.(type) {
+	case *termNode:
+		return n, true
+	case *notNode:
+		term, ok := n.operand.(*termNode)
+		return term, ok
+	}
+
+	return nil, false
+}
+
+// Reports whether post matches the query. An empty query matches every post.
+// Metatags are matched against post metadata
+func (q *Query) Matches(post booru.Post) bool {
+	if q == nil || q.root == nil {
+		return true
+	}
+
+	return q.root.matches(newSubject(booru.AllTags(post), post.Metadata()))
+}
+
+func (q *Query) String() string {
+	conjuncts := q.conjuncts()
+	operands := make([]string, len(conjuncts))
+	for i, conjunct := range conjuncts {
+		operands[i] = conjunct.String()
+	}
+
+	return strings.Join(operands, " ")
+}
+
+// Metatags ordering results instead of narrowing them down
+var orderMetatags = map[string]bool{
+	"order": true,
+	"sort":  true,
+}
+
+// Returns the tags of n if it is a group of alternative plain tags
+func alternatives(n node) ([]string, bool) {
+	or, ok := n.(orNode)
+	if !ok {
+		return nil, false
+	}
+
+	tags := make([]string, len(or))
+	for i, operand := range or {
+		term, ok := operand.(*termNode)
+		if !ok || term.metatag || term.wildcard {
+			return nil, false
+		}
+		tags[i] = term.tag
+	}
+
+	return tags, true
+}
+
+// A query split between a booru and local matching
+type Search struct {
+	// Tags to search for on the booru
+	Tags string
+	// Rest of the query to be matched locally, nil if nothing is left
+	Local *Query
+	// Metatags ordering results left out of the search for lack of room
+	Unordered []string
+	// Set if the query has tags, but none of them fit into the search
+	Unnarrowed bool
+}
+
+// Splits the query into tags to search for on a booru, using at most limit terms (0 for no limit),
+// and the rest of the query to be matched locally. Groups of alternative tags are searched for
+// if the booru understands them with its orSyntax. Metatags that can not be matched locally are
+// always searched for. Then the fewest posts are expected for tags with the lowest postCount, so
+// those are preferred, followed by groups of alternatives, metatags matched locally otherwise,
+// wildcards, negated tags and, last, metatags ordering results, which are left out if there is
+// no room for them. postCount reports false if the amount of posts with tag is unknown, such tags
+// are preferred least. It is only called when there are more tags than the limit allows
+func (q *Query) Split(limit int, orSyntax booru.OrSyntax, postCount func(tag string) (int, bool)) Search {
+	var (
+		server     []string
+		tags       []*termNode
+		groups     [][]string
+		groupNodes []node
+		matchable  []node
+		wildcards  []node
+		negated    []node
+		ordering   []node
+		local      andNode
+		search     Search
+	)
+
+	for _, conjunct := range q.conjuncts() {
+		term, ok := serverTerm(conjunct)
+		_, isNot := conjunct.(*notNode)
+		if !ok {
+			if group, ok := alternatives(conjunct); ok && orSyntax != booru.OrUnsupported {
+				groups = append(groups, group)
+				groupNodes = append(groupNodes, conjunct)
+			} else {
+				local = append(local, conjunct)
+			}
+			continue
+		}
+
+		name, _, _ := strings.Cut(term.tag, ":")
+		switch {
+		case term.metatag && orderMetatags[name]:
+			ordering = append(ordering, conjunct)
+		case term.metatag && term.condition != nil:
+			matchable = append(matchable, conjunct)
+		case term.metatag:
+			server = append(server, conjunct.String())
+		case isNot:
+			negated = append(negated, conjunct)
+		case term.wildcard:
+			wildcards = append(wildcards, conjunct)
+		default:
+			tags = append(tags, term)
+		}
+	}
+
+	free := -1
+	if limit > 0 {
+		free = max(limit-len(server), 0)
+	}
+	// Reports whether a candidate taking slots fits, taking them if it does
+	fits := func(slots int) bool {
+		if free < 0 {
+			return true
+		}
+		if slots > free {
+			return false
+		}
+		free -= slots
+		return true
+	}
+
+	if free >= 0 && len(tags) > free && postCount != nil {
+		counts := make(map[*termNode]int, len(tags))
+		for _, tag := range tags {
+			count, known := postCount(tag.tag)
+			if !known {
+				count = math.MaxInt
+			}
+			counts[tag] = count
+		}
+		sort.SliceStable(tags, func(i, j int) bool {
+			return counts[tags[i]] < counts[tags[j]]
+		})
+	}
+
+	// Searches for candidate if it fits, leaves it to be matched locally otherwise
+	place := func(candidate node) bool {
+		if fits(1) {
+			server = append(server, candidate.String())
+			return true
+		}
+		local = append(local, candidate)
+		return false
+	}
+
+	narrowed := false
+	for _, tag := range tags {
+		if place(tag) {
+			narrowed = true
+		}
+	}
+
+	searchedGroup := false
+	for i, group := range groups {
+		// Tilde groups of a search are all joined into one
+		if (orSyntax != booru.OrTilde || !searchedGroup) && fits(len(group)) {
+			if orSyntax == booru.OrTilde {
+				for _, tag := range group {
+					server = append(server, "~"+tag)
+				}
+			} else {
+				server = append(server, "{"+strings.Join(group, " ~ ")+"}")
+			}
+			searchedGroup = true
+			narrowed = true
+		} else {
+			local = append(local, groupNodes[i])
+		}
+	}
+
+	for _, candidate := range matchable {
+		place(candidate)
+	}
+	for _, candidate := range wildcards {
+		if place(candidate) {
+			narrowed = true
+		}
+	}
+	for _, candidate := range negated {
+		place(candidate)
+	}
+
+	for _, candidate := range ordering {
+		if fits(1) {
+			server = append(server, candidate.String())
+		} else {
+			search.Unordered = append(search.Unordered, candidate.String())
+		}
+	}
+
+	search.Tags = strings.Join(server, " ")
+	search.Unnarrowed = !narrowed && (len(tags) != 0 || len(groups) != 0 || len(wildcards) != 0)
+
+	switch len(local) {
+	case 0:
+	case 1:
+		search.Local = &Query{root: local[0]}
+	default:
+		search.Local = &Query{root: local}
+	}
+
+	return search
+}
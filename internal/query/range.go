@@ -0,0 +1,115 @@
+/*
+   gobooru-downloader
+   Copyright (C) 2025 Kasyanov Nikolay Alexeevich (Unbewohnte)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package query
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A range of numbers written the danbooru way: N, >N, >=N, <N, <=N, N..M, N.. or ..M
+type Range struct {
+	low           float64
+	high          float64
+	lowExclusive  bool
+	highExclusive bool
+	text          string
+}
+
+// Parses a range of numbers. A blank range contains every number
+func ParseRange(value string) (Range, error) {
+	value = strings.TrimSpace(value)
+	numberRange := Range{
+		low:  math.Inf(-1),
+		high: math.Inf(1),
+		text: value,
+	}
+
+	parse := func(number string) (float64, error) {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", strings.TrimSpace(number))
+		}
+		return parsed, nil
+	}
+
+	var err error
+	switch {
+	case value == "":
+
+	case strings.Contains(value, ".."):
+		from, to, _ := strings.Cut(value, "..")
+		if strings.TrimSpace(from) != "" {
+			if numberRange.low, err = parse(from); err != nil {
+				return Range{}, err
+			}
+		}
+		if strings.TrimSpace(to) != "" {
+			if numberRange.high, err = parse(to); err != nil {
+				return Range{}, err
+			}
+		}
+
+	case strings.HasPrefix(value, ">="):
+		numberRange.low, err = parse(value[2:])
+
+	case strings.HasPrefix(value, "<="):
+		numberRange.high, err = parse(value[2:])
+
+	case strings.HasPrefix(value, ">"):
+		numberRange.low, err = parse(value[1:])
+		numberRange.lowExclusive = true
+
+	case strings.HasPrefix(value, "<"):
+		numberRange.high, err = parse(value[1:])
+		numberRange.highExclusive = true
+
+	default:
+		numberRange.low, err = parse(value)
+		numberRange.high = numberRange.low
+	}
+	if err != nil {
+		return Range{}, err
+	}
+
+	return numberRange, nil
+}
+
+// Reports whether number is within the range
+func (numberRange Range) Contains(number float64) bool {
+	if number < numberRange.low || (numberRange.lowExclusive && number == numberRange.low) {
+		return false
+	}
+	if number > numberRange.high || (numberRange.highExclusive && number == numberRange.high) {
+		return false
+	}
+
+	return true
+}
+
+// Reports whether the range contains every number
+func (numberRange Range) IsAny() bool {
+	return math.IsInf(numberRange.low, -1) && math.IsInf(numberRange.high, 1)
+}
+
+func (numberRange Range) String() string {
+	return numberRange.text
+}